@@ -0,0 +1,46 @@
+/*
+Copyright © 2020 A. Jensen <jensen.aaro@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/spf13/viper"
+
+	"github.com/ajjensen13/dayspa/internal/admin"
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/serve"
+)
+
+type adminAddrType string
+
+func provideAdminAddr(v *viper.Viper) adminAddrType {
+	return adminAddrType(v.GetString("admin-addr"))
+}
+
+// adminServer serves the admin endpoints. Server is nil if the admin listener is disabled.
+type adminServer struct {
+	Server *http.Server
+}
+
+func provideAdminServer(handler *serve.SiteHandler, r *reloader, lg logging.Logger, addr adminAddrType) adminServer {
+	if addr == "" {
+		return adminServer{}
+	}
+	return adminServer{Server: newServer(admin.Handler(handler, r.Reload, lg), lg, string(addr))}
+}
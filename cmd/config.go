@@ -0,0 +1,143 @@
+/*
+Copyright © 2020 A. Jensen <jensen.aaro@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix is the prefix of environment variables that configure dayspa.
+// For example, --webroot can be set with DAYSPA_WEBROOT.
+const envPrefix = "DAYSPA"
+
+// listSeparators are the separators of list settings when they are given as a single
+// string (e.g. DAYSPA_ENCODINGS=br,gzip). Cache rules are separated by ";", since
+// Cache-Control values contain commas.
+var listSeparators = map[string]string{
+	"encodings":        ",",
+	"fallback-exclude": ",",
+	"cache-rule":       ";",
+}
+
+// splitLists replaces list settings that were given as a single string with the list
+// they describe. Viper would otherwise split environment variables on whitespace.
+func splitLists(v *viper.Viper) {
+	for key, sep := range listSeparators {
+		s, ok := v.Get(key).(string)
+		if !ok {
+			continue
+		}
+
+		result := []string{}
+		for _, item := range strings.Split(s, sep) {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+		v.Set(key, result)
+	}
+}
+
+// loadConfig merges the config file, environment variables and flags (in
+// increasing order of precedence) into the effective configuration.
+func loadConfig(cmd *cobra.Command) (*viper.Viper, error) {
+	v := viper.New()
+
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+
+	err := v.BindPFlags(cmd.Flags())
+	if err != nil {
+		return nil, err
+	}
+
+	if cfgFile != "" {
+		v.SetConfigFile(cfgFile)
+		err = v.ReadInConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", cfgFile, err)
+		}
+		splitLists(v)
+		return v, nil
+	}
+
+	// The default config file is optional, and may be .yaml, .toml or .json.
+	home, err := os.UserHomeDir()
+	if err != nil {
+		splitLists(v)
+		return v, nil
+	}
+
+	v.SetConfigName(".dayspa")
+	v.AddConfigPath(home)
+
+	err = v.ReadInConfig()
+	if err != nil && !errors.As(err, &viper.ConfigFileNotFoundError{}) {
+		return nil, fmt.Errorf("failed to read config file %s: %w", v.ConfigFileUsed(), err)
+	}
+
+	splitLists(v)
+	return v, nil
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration",
+	Long:  "Print the configuration that results from merging the config file, DAYSPA_* environment variables and flags.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		settings := v.AllSettings()
+		delete(settings, "config")
+		delete(settings, "help")
+
+		out, err := yaml.Marshal(settings)
+		if err != nil {
+			return err
+		}
+
+		w := cmd.OutOrStdout()
+		if f := v.ConfigFileUsed(); f != "" {
+			_, _ = fmt.Fprintf(w, "# config file: %s\n", f)
+		}
+		_, err = w.Write(out)
+		return err
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configPrintCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2020 A. Jensen <jensen.aaro@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ajjensen13/dayspa/internal/logging"
+)
+
+// shutdownTimeout is how long in-flight requests are given to complete during shutdown.
+const shutdownTimeout = time.Second * 10
+
+// aliveContext returns a context that is canceled when the process is asked to stop.
+func aliveContext(lg logging.Logger) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		defer signal.Stop(c)
+		select {
+		case s := <-c:
+			lg.Noticef("signal received: %v", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// newServer returns a server with sensible timeouts.
+func newServer(handler http.Handler, lg logging.Logger, addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       time.Second * 30,
+		ReadHeaderTimeout: time.Second * 5,
+		WriteTimeout:      time.Second * 30,
+		IdleTimeout:       time.Second * 60,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		ErrorLog:          logging.StandardLogger(lg),
+	}
+}
+
+// listenAndServe serves until ctx is done, then shuts the server down gracefully.
+func listenAndServe(ctx context.Context, srv *http.Server, name string, lg logging.Logger) error {
+	errs := make(chan error, 1)
+	go func() { errs <- srv.ListenAndServe() }()
+
+	lg.Noticef("%s listening on %s", name, srv.Addr)
+
+	select {
+	case err := <-errs:
+		return lg.ErrorErr(fmt.Errorf("%s failed: %w", name, err))
+	case <-ctx.Done():
+	}
+
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := srv.Shutdown(sctx)
+	if err != nil {
+		return lg.ErrorErr(fmt.Errorf("%s failed to shutdown gracefully: %w", name, err))
+	}
+
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return lg.ErrorErr(fmt.Errorf("%s failed: %w", name, err))
+	}
+
+	lg.Noticef("%s shutdown gracefully", name)
+	return nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2020 A. Jensen <jensen.aaro@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cmd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+	"github.com/ajjensen13/dayspa/internal/serve"
+	"github.com/ajjensen13/dayspa/internal/watch"
+)
+
+// siteLoader loads the site from the webroot.
+type siteLoader func() (*manifest.Site, error)
+
+// reloader rebuilds the site and swaps it into the handler once it is fully loaded.
+type reloader struct {
+	load    siteLoader
+	handler *serve.SiteHandler
+	lg      logging.Logger
+	mu      sync.Mutex
+}
+
+func provideReloader(load siteLoader, handler *serve.SiteHandler, lg logging.Logger) *reloader {
+	return &reloader{load: load, handler: handler, lg: lg}
+}
+
+type reloadEntry struct {
+	OldChecksum string `json:"old_checksum"`
+	NewChecksum string `json:"new_checksum"`
+	Changed     bool   `json:"changed"`
+	Duration    string `json:"duration"`
+}
+
+// Reload loads the site and swaps it into the handler. If loading fails, the
+// previous site continues to be served.
+func (r *reloader) Reload() (*manifest.Site, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := time.Now()
+	site, err := r.load()
+	if err != nil {
+		return nil, r.lg.ErrorErr(err)
+	}
+
+	old, err := r.handler.Swap(site)
+	if err != nil {
+		return nil, r.lg.ErrorErr(err)
+	}
+
+	r.lg.Notice(logging.NewMsgData("reloaded site", reloadEntry{
+		OldChecksum: old.Checksum,
+		NewChecksum: site.Checksum,
+		Changed:     old.Checksum != site.Checksum,
+		Duration:    time.Since(start).String(),
+	}))
+
+	return site, nil
+}
+
+type watchConfig struct {
+	Enabled bool
+	// Root is the directory or archive that is watched.
+	Root string
+	watch.Config
+}
+
+func provideWatchConfig(v *viper.Viper, webroot webRoot, archivePath archivePath) (result watchConfig) {
+	result.Enabled = v.GetBool("watch")
+	result.Root = string(webroot)
+	if archivePath != "" {
+		result.Root = string(archivePath)
+	}
+	result.Debounce = v.GetDuration("watch-debounce")
+	result.PollInterval = v.GetDuration("watch-poll-interval")
+	result.Poll = v.GetBool("watch-poll")
+	return
+}
@@ -19,49 +19,74 @@ package cmd
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"fmt"
 	"github.com/spf13/cobra"
+	"io/fs"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
-	"github.com/ajjensen13/gke"
+	"github.com/spf13/viper"
 
+	"github.com/ajjensen13/dayspa/internal/archive"
+	"github.com/ajjensen13/dayspa/internal/cache"
 	"github.com/ajjensen13/dayspa/internal/load"
+	"github.com/ajjensen13/dayspa/internal/logging"
 	"github.com/ajjensen13/dayspa/internal/manifest"
 	"github.com/ajjensen13/dayspa/internal/serve"
+	"github.com/ajjensen13/dayspa/internal/watch"
 )
 
 var rootCmd = &cobra.Command{
 	Use: "dayspa",
+	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
+		config, err = loadConfig(cmd)
+		return
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		lg, cleanup, err := gke.NewLogger(context.Background())
+		lg, cleanup, err := logging.New(context.Background(), logging.Backend(config.GetString("log")))
 		if err != nil {
 			panic(err)
 		}
 		defer cleanup()
 
-		gke.LogEnv(lg)
-		gke.LogMetadata(lg)
-
-		gke.Do(func(ctx context.Context) error {
-			srv, err := InjectServer(ctx, lg, cmd)
-			if err != nil {
-				panic(lg.ErrorErr(err))
-			}
-
-			switch err := srv.ListenAndServe(); {
-			case errors.Is(err, http.ErrServerClosed):
-				lg.Noticef("server shutdown gracefully")
-				return nil
-			default:
-				return lg.ErrorErr(err)
-			}
-		})
-
-		<-gke.AfterAliveContext(time.Second * 10).Done()
+		ctx, cancel := aliveContext(lg)
+		defer cancel()
+
+		a, err := InjectApp(ctx, lg, config)
+		if err != nil {
+			panic(lg.ErrorErr(err))
+		}
+
+		if a.Watch.Enabled {
+			go func() {
+				_ = watch.Watch(ctx, a.Watch.Root, a.Watch.Config, lg, func() { _, _ = a.Reloader.Reload() })
+			}()
+		}
+
+		var wg sync.WaitGroup
+		if a.Admin.Server != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := listenAndServe(ctx, a.Admin.Server, "admin server", lg); err != nil {
+					cancel()
+				}
+			}()
+		}
+
+		err = listenAndServe(ctx, a.Server, "server", lg)
+		cancel()
+		wg.Wait()
+
+		if err != nil {
+			cleanup()
+			os.Exit(1)
+		}
 	},
 }
 
@@ -75,66 +100,142 @@ func Execute() {
 var (
 	cfgFile string
 	mode    string
+	config  *viper.Viper
 )
 
 func init() {
 	flags := rootCmd.PersistentFlags()
 	flags.StringVar(&cfgFile, "config", "", "config file (default is $HOME/.dayspa.yaml)")
-	flags.StringVarP(&mode, "mode", "m", "", "mode to use (currently, only \"ngsw\" is supported)")
-	flags.StringP("webroot", "w", ".", "Web root directory")
+	flags.StringVarP(&mode, "mode", "m", load.Auto, "mode to use, which determines how the webroot is loaded:\n"+modeUsage())
+	flags.StringP("webroot", "w", ".", "Web root directory (relative to the root of the archive, if --archive is set)")
+	flags.String("archive", "", fmt.Sprintf("archive to load the site from, without extracting it (one of: %s)", strings.Join(archive.Extensions, ", ")))
 	flags.StringP("addr", "a", ":http", "address to listen on")
+	flags.String("log", string(logging.Json), fmt.Sprintf("log backend to use (one of: %s)", logging.BackendNames()))
+	flags.String("admin-addr", "", "address for the admin endpoints to listen on (disabled if empty)")
+	flags.Bool("watch", false, "reload the site when files in the webroot change")
+	flags.Duration("watch-debounce", time.Second*2, "how long the webroot must be unchanged before it is reloaded")
+	flags.Duration("watch-poll-interval", time.Second*5, "how often the webroot is scanned when inotify is unavailable")
+	flags.Bool("watch-poll", false, "scan the webroot for changes instead of using inotify")
+	flags.Int("etag-length", 16, "number of hash characters used in ETag headers (0 uses the full hash)")
+	flags.StringSlice("encodings", nil, "content encodings to serve, in addition to identity (default all)")
+	flags.Bool("fallback", serve.DefaultConfig().Fallback.Enabled, "answer navigation requests for unknown paths with the index")
+	flags.StringSlice("fallback-exclude", nil, "glob patterns that are never answered with the index (e.g. \"/api/**\")")
+	flags.Bool("fallback-strict", false, "keep 404 responses for unknown paths that look like files")
+	flags.String("cache-default", serve.DefaultConfig().Cache.Default, "Cache-Control value for assets that are neither hashed nor an index")
+	flags.StringArray("cache-rule", nil, "Cache-Control override of the form <selector>=<value>, where selector is a glob (e.g. \"/assets/**\"), \"source:<name>\", \"lazy\" or \"eager\" (separate rules with \";\" in DAYSPA_CACHE_RULE)")
+
+	for _, l := range load.Loaders() {
+		l.Flags(flags)
+	}
 }
 
 type webRoot string
 
-func provideWebRoot(cmd *cobra.Command) (webRoot, error) {
-	result, err := cmd.Flags().GetString("webroot")
-	if err != nil {
-		return "", err
-	}
-	return webRoot(result), nil
+func provideWebRoot(v *viper.Viper) webRoot {
+	return webRoot(v.GetString("webroot"))
 }
 
-type modeType string
+type archivePath string
 
-func provideMode(cmd *cobra.Command) (modeType, error) {
-	result, err := cmd.Flags().GetString("mode")
-	if err != nil {
-		return "", err
+func provideArchivePath(v *viper.Viper) archivePath {
+	return archivePath(v.GetString("archive"))
+}
+
+// webRootFS opens the file system that the site is loaded from. It is called for every
+// load, so that reloads see a replaced archive.
+type webRootFS func() (fs.FS, error)
+
+func provideWebRootFS(webroot webRoot, archivePath archivePath) webRootFS {
+	if archivePath == "" {
+		fsys := load.Dir(string(webroot))
+		return func() (fs.FS, error) { return fsys, nil }
+	}
+
+	return func() (fs.FS, error) {
+		return archive.Open(string(archivePath), filepath.ToSlash(string(webroot)))
 	}
-	return modeType(result), nil
 }
 
-func provideSite(webroot webRoot, mode modeType, lg gke.Logger) (*manifest.Site, error) {
-	switch mode {
-	case "ngsw":
-		return load.Ngsw(string(webroot), lg)
-	case "filesystem":
-		return load.Filesystem(string(webroot), lg)
-	default:
-		return nil, fmt.Errorf("unsupported mode: %s (try --mode=ngsw)", mode)
+type modeType string
+
+func provideMode(v *viper.Viper) modeType {
+	if m := v.GetString("mode"); m != "" {
+		return modeType(m)
 	}
+	return load.Auto
 }
 
-func provideHandler(site *manifest.Site, lg gke.Logger) http.Handler {
-	return serve.Handler(site, lg)
+func provideLoadOptions(v *viper.Viper) (result load.Options) {
+	result.ETagLength = v.GetInt("etag-length")
+	return
 }
 
-type addrType string
+// modeUsage lists the registered loaders for the --mode help text.
+func modeUsage() string {
+	var lines []string
+	for _, l := range load.Loaders() {
+		lines = append(lines, fmt.Sprintf("  %s: %s", l.Name(), l.Description()))
+	}
+	return strings.Join(lines, "\n")
+}
 
-func provideAddr(cmd *cobra.Command) (addrType, error) {
-	result, err := cmd.Flags().GetString("addr")
+func provideSiteLoader(open webRootFS, mode modeType, v *viper.Viper, opts load.Options, lg logging.Logger) (siteLoader, error) {
+	loader, err := load.Lookup(string(mode))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return addrType(result), nil
+
+	return func() (*manifest.Site, error) {
+		fsys, err := open()
+		if err != nil {
+			return nil, err
+		}
+		return loader.Load(fsys, v, opts, lg)
+	}, nil
+}
+
+func provideSite(load siteLoader) (*manifest.Site, error) {
+	return load()
 }
 
-func provideServer(ctx context.Context, handler http.Handler, lg gke.Logger, addr addrType) (*http.Server, error) {
-	result, err := gke.NewServer(ctx, handler, lg)
+func provideServeConfig(v *viper.Viper) (result serve.Config, err error) {
+	result.Fallback.Enabled = v.GetBool("fallback")
+	result.Fallback.Exclude = v.GetStringSlice("fallback-exclude")
+	result.Fallback.Strict = v.GetBool("fallback-strict")
+	result.Cache.Default = v.GetString("cache-default")
+	result.Cache.Rules, err = cache.ParseRules(v.GetStringSlice("cache-rule"))
 	if err != nil {
-		return nil, err
+		return
+	}
+
+	for _, name := range v.GetStringSlice("encodings") {
+		e, err := manifest.ParseContentEncoding(name)
+		if err != nil {
+			return result, err
+		}
+		result.Encodings = append(result.Encodings, e)
 	}
-	result.Addr = string(addr)
-	return result, nil
+	return
+}
+
+func provideHandler(site *manifest.Site, lg logging.Logger, cfg serve.Config) (*serve.SiteHandler, error) {
+	return serve.Handler(site, lg, cfg)
+}
+
+// app is everything needed to run dayspa.
+type app struct {
+	Server   *http.Server
+	Admin    adminServer
+	Reloader *reloader
+	Watch    watchConfig
+}
+
+type addrType string
+
+func provideAddr(v *viper.Viper) addrType {
+	return addrType(v.GetString("addr"))
+}
+
+func provideServer(handler http.Handler, lg logging.Logger, addr addrType) *http.Server {
+	return newServer(handler, lg, string(addr))
 }
@@ -21,12 +21,32 @@ package cmd
 
 import (
 	"context"
-	"github.com/ajjensen13/gke"
 	"github.com/google/wire"
-	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"net/http"
+
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/serve"
 )
 
-func InjectServer(ctx context.Context, lg gke.Logger, cmd *cobra.Command) (*http.Server, error) {
-	panic(wire.Build(provideWebRoot, provideSite, provideHandler, provideServer, provideMode, provideAddr))
+func InjectApp(ctx context.Context, lg logging.Logger, v *viper.Viper) (*app, error) {
+	panic(wire.Build(
+		provideWebRoot,
+		provideArchivePath,
+		provideWebRootFS,
+		provideMode,
+		provideLoadOptions,
+		provideSiteLoader,
+		provideSite,
+		provideServeConfig,
+		provideHandler,
+		wire.Bind(new(http.Handler), new(*serve.SiteHandler)),
+		provideReloader,
+		provideWatchConfig,
+		provideAddr,
+		provideServer,
+		provideAdminAddr,
+		provideAdminServer,
+		wire.Struct(new(app), "*"),
+	))
 }
@@ -7,34 +7,45 @@ package cmd
 
 import (
 	"context"
-	"github.com/ajjensen13/gke"
-	"github.com/spf13/cobra"
-	"net/http"
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/spf13/viper"
 )
 
 // Injectors from wire.go:
 
-func InjectServer(ctx context.Context, lg gke.Logger, cmd *cobra.Command) (*http.Server, error) {
-	cmdWebRoot, err := provideWebRoot(cmd)
+func InjectApp(ctx context.Context, lg logging.Logger, v *viper.Viper) (*app, error) {
+	cmdWebRoot := provideWebRoot(v)
+	cmdArchivePath := provideArchivePath(v)
+	cmdWebRootFS := provideWebRootFS(cmdWebRoot, cmdArchivePath)
+	cmdModeType := provideMode(v)
+	v2 := provideLoadOptions(v)
+	cmdSiteLoader, err := provideSiteLoader(cmdWebRootFS, cmdModeType, v, v2, lg)
 	if err != nil {
 		return nil, err
 	}
-	cmdModeType, err := provideMode(cmd)
+	site, err := provideSite(cmdSiteLoader)
 	if err != nil {
 		return nil, err
 	}
-	site, err := provideSite(cmdWebRoot, cmdModeType, lg)
+	serveConfig, err := provideServeConfig(v)
 	if err != nil {
 		return nil, err
 	}
-	handler := provideHandler(site, lg)
-	cmdAddrType, err := provideAddr(cmd)
+	siteHandler, err := provideHandler(site, lg, serveConfig)
 	if err != nil {
 		return nil, err
 	}
-	server, err := provideServer(ctx, handler, lg, cmdAddrType)
-	if err != nil {
-		return nil, err
+	cmdAddrType := provideAddr(v)
+	server := provideServer(siteHandler, lg, cmdAddrType)
+	cmdReloader := provideReloader(cmdSiteLoader, siteHandler, lg)
+	cmdAdminAddrType := provideAdminAddr(v)
+	cmdAdminServer := provideAdminServer(siteHandler, cmdReloader, lg, cmdAdminAddrType)
+	cmdWatchConfig := provideWatchConfig(v, cmdWebRoot, cmdArchivePath)
+	cmdApp := &app{
+		Server:   server,
+		Admin:    cmdAdminServer,
+		Reloader: cmdReloader,
+		Watch:    cmdWatchConfig,
 	}
-	return server, nil
+	return cmdApp, nil
 }
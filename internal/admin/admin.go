@@ -0,0 +1,169 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package admin provides an http.Handler to inspect and reload the site being served.
+//
+// The following endpoints are provided:
+//
+//	POST /reload        reloads the site from the webroot
+//	GET  /site          returns the site being served
+//	GET  /assets/{url}  returns a single asset of the site being served
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+// SiteSource provides the site currently being served.
+type SiteSource interface {
+	Site() *manifest.Site
+}
+
+// ReloadFunc reloads the site, and returns the newly loaded site.
+type ReloadFunc func() (*manifest.Site, error)
+
+// Handler returns an http.Handler that serves the admin endpoints.
+func Handler(sites SiteSource, reload ReloadFunc, lg logging.Logger) http.Handler {
+	h := handler{Sites: sites, Reload: reload, Logger: lg}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reload", h.serveReload)
+	mux.HandleFunc("/site", h.serveSite)
+	mux.HandleFunc("/assets/", h.serveAsset)
+	return mux
+}
+
+type handler struct {
+	Sites  SiteSource
+	Reload ReloadFunc
+	Logger logging.Logger
+}
+
+type siteView struct {
+	Index    string      `json:"index"`
+	Checksum string      `json:"checksum"`
+	Assets   []assetView `json:"assets"`
+}
+
+type assetView struct {
+	Url         string               `json:"url"`
+	File        string               `json:"file"`
+	ContentType manifest.ContentType `json:"content_type"`
+	Etag        string               `json:"etag"`
+	Lazy        bool                 `json:"lazy"`
+	Source      string               `json:"source"`
+	Group       string               `json:"group,omitempty"`
+	ModTime     time.Time            `json:"mod_time"`
+	Sizes       map[string]int       `json:"sizes"`
+}
+
+func newSiteView(site *manifest.Site) siteView {
+	result := siteView{
+		Index:    site.Index,
+		Checksum: site.Checksum,
+		Assets:   make([]assetView, 0, len(site.Assets)),
+	}
+
+	for _, asset := range site.Assets {
+		result.Assets = append(result.Assets, newAssetView(asset))
+	}
+	return result
+}
+
+func newAssetView(asset *manifest.EncodedAsset) assetView {
+	result := assetView{
+		Url:         asset.Url,
+		File:        asset.File,
+		ContentType: asset.ContentType,
+		Etag:        asset.Etag,
+		Lazy:        asset.Lazy,
+		Source:      asset.Source,
+		Group:       asset.Group,
+		ModTime:     asset.ModTime,
+		Sizes:       make(map[string]int, len(asset.Data)),
+	}
+
+	for _, datum := range asset.Data {
+		result.Sizes[datum.ContentEncoding.String()] = len(datum.Data)
+	}
+	return result
+}
+
+func (h *handler) serveReload(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(wr, http.MethodPost)
+		return
+	}
+
+	site, err := h.Reload()
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(wr, newSiteView(site))
+}
+
+func (h *handler) serveSite(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(wr, "GET, HEAD")
+		return
+	}
+
+	h.writeJSON(wr, newSiteView(h.Sites.Site()))
+}
+
+func (h *handler) serveAsset(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(wr, "GET, HEAD")
+		return
+	}
+
+	url := strings.TrimPrefix(r.URL.Path, "/assets")
+	for _, asset := range h.Sites.Site().Assets {
+		if asset.Url == url {
+			h.writeJSON(wr, newAssetView(asset))
+			return
+		}
+	}
+
+	http.NotFound(wr, r)
+}
+
+func (h *handler) writeJSON(wr http.ResponseWriter, v interface{}) {
+	wr.Header().Set("Content-Type", "application/json; charset=utf-8")
+	wr.Header().Set("Cache-Control", "no-store")
+
+	enc := json.NewEncoder(wr)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(v)
+	if err != nil {
+		h.Logger.Warningf("failed to write admin response: %v", err)
+	}
+}
+
+func methodNotAllowed(wr http.ResponseWriter, allow string) {
+	wr.Header().Set("Allow", allow)
+	http.Error(wr, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
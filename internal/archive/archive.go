@@ -0,0 +1,146 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package archive reads a webroot from a tar, tar.gz or zip archive into memory,
+// so that it can be loaded without being extracted to disk.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// Extensions are the file extensions of the supported archive formats.
+var Extensions = []string{".tar", ".tar.gz", ".tgz", ".zip"}
+
+// Open reads the archive at fpath into memory. The format is determined by the
+// file extension (one of Extensions). The returned file system is rooted at the
+// directory root within the archive (e.g. "dist/app", or "." for the whole archive).
+func Open(fpath, root string) (fs.FS, error) {
+	data, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	var fsys fs.FS
+	lower := strings.ToLower(fpath)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		fsys, err = zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			break
+		}
+		fsys, err = readTar(gz)
+	case strings.HasSuffix(lower, ".tar"):
+		fsys, err = readTar(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported archive %s (expected one of: %s)", fpath, strings.Join(Extensions, ", "))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", fpath, err)
+	}
+
+	root, ok := cleanName(root)
+	if !ok {
+		return nil, fmt.Errorf("invalid root %q in archive %s", root, fpath)
+	}
+
+	fsys, err = fs.Sub(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &archiveFS{FS: fsys, path: fpath, root: root}, nil
+}
+
+// archiveFS describes itself by the path to the archive when it is logged.
+type archiveFS struct {
+	fs.FS
+	path string
+	root string
+}
+
+// String implements fmt.Stringer.String()
+func (a *archiveFS) String() string {
+	if a.root == "." {
+		return a.path
+	}
+	return a.path + ":" + a.root
+}
+
+// readTar reads every regular file in a tar archive into memory.
+func readTar(r io.Reader) (memFS, error) {
+	result := newMemFS()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name, ok := cleanName(hdr.Name)
+		if !ok {
+			return nil, fmt.Errorf("invalid file name %q", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := result.addDir(name, hdr.FileInfo().Mode(), hdr.ModTime); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if name == "." {
+				return nil, fmt.Errorf("invalid file name %q", hdr.Name)
+			}
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+			}
+			if err := result.addFile(name, data, hdr.FileInfo().Mode(), hdr.ModTime); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// cleanName converts a name from an archive header (e.g. "./dist/index.html") into a valid fs.FS name.
+func cleanName(name string) (string, bool) {
+	name = path.Clean("/" + name)
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
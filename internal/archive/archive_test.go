@@ -0,0 +1,120 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+type tarEntry struct {
+	name string
+	dir  bool
+	data string
+}
+
+func buildTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.data))}
+		if e.dir {
+			hdr = tar.Header{Name: e.name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestReadTar(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		files   map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "files and directories",
+			entries: []tarEntry{{name: "./", dir: true}, {name: "./dist/", dir: true}, {name: "./dist/index.html", data: "<html>"}, {name: "dist/assets/x.txt", data: "x"}},
+			files:   map[string]string{"dist/index.html": "<html>", "dist/assets/x.txt": "x"},
+		},
+		{
+			name:    "file then file beneath it",
+			entries: []tarEntry{{name: "a", data: "a"}, {name: "a/b", data: "b"}},
+			wantErr: true,
+		},
+		{
+			name:    "file then directory with the same name",
+			entries: []tarEntry{{name: "a", data: "a"}, {name: "a/", dir: true}},
+			wantErr: true,
+		},
+		{
+			name:    "directory then file with the same name",
+			entries: []tarEntry{{name: "a/", dir: true}, {name: "a/b", data: "b"}, {name: "a", data: "a"}},
+			wantErr: true,
+		},
+		{
+			name:    "parent references are cleaned",
+			entries: []tarEntry{{name: "../a", data: "a"}},
+			files:   map[string]string{"a": "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, err := readTar(buildTar(t, tt.entries))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readTar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			names := make([]string, 0, len(tt.files))
+			for name := range tt.files {
+				names = append(names, name)
+			}
+			if err := fstest.TestFS(fsys, names...); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range tt.files {
+				got, err := fs.ReadFile(fsys, name)
+				if err != nil {
+					t.Fatalf("ReadFile(%q) error = %v", name, err)
+				}
+				if string(got) != want {
+					t.Errorf("ReadFile(%q) = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
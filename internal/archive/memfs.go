@@ -0,0 +1,195 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// memFS is a read-only in-memory file system, keyed by the full name of each file
+// and directory. Parent directories are created as files are added.
+type memFS map[string]*memNode
+
+func newMemFS() memFS {
+	return memFS{".": &memNode{name: ".", mode: fs.ModeDir | 0555, children: make(map[string]*memNode)}}
+}
+
+// addFile adds a regular file, replacing any earlier file with the same name.
+func (m memFS) addFile(name string, data []byte, mode fs.FileMode, modTime time.Time) error {
+	if existing, ok := m[name]; ok && existing.IsDir() {
+		return conflictError(name)
+	}
+
+	parent, err := m.dir(path.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	n := &memNode{name: path.Base(name), data: data, mode: mode.Perm(), modTime: modTime}
+	parent.children[n.name] = n
+	m[name] = n
+	return nil
+}
+
+// addDir adds a directory. Adding a directory that already exists updates its mode and modification time.
+func (m memFS) addDir(name string, mode fs.FileMode, modTime time.Time) error {
+	n, err := m.dir(name)
+	if err != nil {
+		return err
+	}
+
+	n.mode, n.modTime = fs.ModeDir|mode.Perm(), modTime
+	return nil
+}
+
+// dir returns the directory name, creating it and its parents if they do not exist.
+func (m memFS) dir(name string) (*memNode, error) {
+	if n, ok := m[name]; ok {
+		if !n.IsDir() {
+			return nil, conflictError(name)
+		}
+		return n, nil
+	}
+
+	parent, err := m.dir(path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+
+	n := &memNode{name: path.Base(name), mode: fs.ModeDir | 0555, children: make(map[string]*memNode)}
+	parent.children[n.name] = n
+	m[name] = n
+	return n, nil
+}
+
+func conflictError(name string) error {
+	return fmt.Errorf("invalid archive: %s is both a file and a directory", name)
+}
+
+// Open implements fs.FS.Open()
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	n, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if !n.IsDir() {
+		return &memFile{node: n, Reader: bytes.NewReader(n.data)}, nil
+	}
+
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for _, child := range n.children {
+		entries = append(entries, child)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	return &memDir{node: n, path: name, entries: entries}, nil
+}
+
+// memNode is a file or directory in a memFS. It is its own fs.FileInfo and fs.DirEntry.
+type memNode struct {
+	name     string
+	data     []byte
+	mode     fs.FileMode
+	modTime  time.Time
+	children map[string]*memNode
+}
+
+// Name implements fs.FileInfo.Name()
+func (n *memNode) Name() string { return n.name }
+
+// Size implements fs.FileInfo.Size()
+func (n *memNode) Size() int64 { return int64(len(n.data)) }
+
+// Mode implements fs.FileInfo.Mode()
+func (n *memNode) Mode() fs.FileMode { return n.mode }
+
+// ModTime implements fs.FileInfo.ModTime()
+func (n *memNode) ModTime() time.Time { return n.modTime }
+
+// IsDir implements fs.FileInfo.IsDir()
+func (n *memNode) IsDir() bool { return n.mode.IsDir() }
+
+// Sys implements fs.FileInfo.Sys()
+func (n *memNode) Sys() interface{} { return nil }
+
+// Type implements fs.DirEntry.Type()
+func (n *memNode) Type() fs.FileMode { return n.mode.Type() }
+
+// Info implements fs.DirEntry.Info()
+func (n *memNode) Info() (fs.FileInfo, error) { return n, nil }
+
+// memFile is an open regular file.
+type memFile struct {
+	node *memNode
+	*bytes.Reader
+}
+
+// Stat implements fs.File.Stat()
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.node, nil }
+
+// Close implements fs.File.Close()
+func (f *memFile) Close() error { return nil }
+
+// memDir is an open directory.
+type memDir struct {
+	node    *memNode
+	path    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+// Stat implements fs.File.Stat()
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.node, nil }
+
+// Read implements fs.File.Read()
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: fs.ErrInvalid}
+}
+
+// Close implements fs.File.Close()
+func (d *memDir) Close() error { return nil }
+
+// ReadDir implements fs.ReadDirFile.ReadDir()
+func (d *memDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.offset += count
+	return rest[:count], nil
+}
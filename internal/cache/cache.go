@@ -0,0 +1,238 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package cache assigns Cache-Control policies to the assets of a site.
+package cache
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ajjensen13/dayspa/internal/glob"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+const (
+	// Immutable is the Cache-Control value for assets whose url changes with their content.
+	Immutable = "public, max-age=31536000, immutable"
+	// NoCache is the Cache-Control value for assets that must be revalidated on every use.
+	NoCache = "no-cache"
+)
+
+// Class is a class of assets that share a built-in Cache-Control policy.
+type Class string
+
+const (
+	// ClassIndex is the site's index, and any directory index.
+	ClassIndex Class = "index"
+	// ClassManifest is a file that describes the build, such as ngsw.json, or a service worker script.
+	ClassManifest Class = "manifest"
+	// ClassHashed is a file with a content hash in its name, such as main.3f2a1b.js.
+	ClassHashed Class = "hashed"
+	// ClassDefault is any other asset.
+	ClassDefault Class = "default"
+)
+
+// manifestFiles are files that describe a build. They are loaded by the browser
+// at a fixed url, so they must never be served stale.
+var manifestFiles = map[string]bool{
+	"ngsw.json":           true,
+	"ngsw-worker.js":      true,
+	"safety-worker.js":    true,
+	"worker-basic.min.js": true,
+}
+
+// hexHash matches file names with a hex content hash, e.g. main.3f2a1b.js or 787.cf9d1234.chunk.js.
+var hexHash = regexp.MustCompile(`[.-]([0-9a-f]{6,})(\.[0-9a-z]+)+$`)
+
+// base64Hash matches file names with a base64url content hash, e.g. index-BkX3a9_Z.js, as
+// written by Vite (Rollup 4). Plain names (e.g. icon-settings.svg) look the same, so it is
+// only used for files that the vite loader found in the build manifest.
+var base64Hash = regexp.MustCompile(`-[A-Za-z0-9_-]{8,}\.[0-9a-z]+$`)
+
+// base32Hash matches file names with an uppercase base32 content hash, e.g. main-2QNK2WDW.js,
+// as written by esbuild (the Angular application builder). Like base64Hash, it is only used
+// for files that a loader found in a build manifest.
+var base32Hash = regexp.MustCompile(`-[A-Z0-9]{8}\.[0-9a-z]+$`)
+
+// base32Sources are the loaders whose build manifests may list esbuild output.
+var base32Sources = map[string]bool{
+	"ngsw.json": true,
+	"webpack":   true,
+	"cra":       true,
+}
+
+// hashed returns true if the asset's name contains a content hash.
+func hashed(asset *manifest.EncodedAsset) bool {
+	base := path.Base(asset.Url)
+
+	// Hashes contain a digit, which keeps words such as "facade" from looking like hashes.
+	if m := hexHash.FindStringSubmatch(base); m != nil && strings.ContainsAny(m[1], "0123456789") {
+		return true
+	}
+
+	switch {
+	case asset.Source == "vite":
+		return base64Hash.MatchString(base)
+	case base32Sources[asset.Source]:
+		return base32Hash.MatchString(base)
+	default:
+		return false
+	}
+}
+
+// Classify determines the built-in class of an asset.
+func Classify(site *manifest.Site, asset *manifest.EncodedAsset) Class {
+	base := path.Base(asset.Url)
+	switch {
+	case asset.Url == site.Index || base == "index.html":
+		return ClassIndex
+	case manifestFiles[base]:
+		return ClassManifest
+	case hashed(asset):
+		return ClassHashed
+	default:
+		return ClassDefault
+	}
+}
+
+// Rule overrides the built-in policy for the assets matched by its Selector.
+// Rules are usually built with ParseRule; see ParseRule for the supported selectors.
+type Rule struct {
+	Selector string
+	Value    string
+	match    func(asset *manifest.EncodedAsset) bool
+}
+
+// Match returns true if the rule applies to the asset. A rule with an invalid
+// selector matches nothing.
+func (r Rule) Match(asset *manifest.EncodedAsset) bool {
+	match := r.match
+	if match == nil {
+		var err error
+		match, err = matcher(r.Selector)
+		if err != nil {
+			return false
+		}
+	}
+	return match(asset)
+}
+
+// ParseRule parses a rule of the form "<selector>=<cache-control>". The selector is one of:
+//
+//	/<glob>        assets whose url matches the glob, e.g. "/assets/**=public, max-age=86400"
+//	source:<name>  assets loaded from the named source, e.g. "source:filesystem=no-cache"
+//	lazy           assets that are lazily loaded by the app
+//	eager          assets that are eagerly loaded by the app
+func ParseRule(s string) (Rule, error) {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 {
+		return Rule{}, fmt.Errorf("invalid cache rule %q: expected <selector>=<cache-control>", s)
+	}
+
+	result := Rule{Selector: strings.TrimSpace(kv[0]), Value: strings.TrimSpace(kv[1])}
+
+	var err error
+	result.match, err = matcher(result.Selector)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid cache rule %q: %w", s, err)
+	}
+
+	return result, nil
+}
+
+func matcher(sel string) (func(asset *manifest.EncodedAsset) bool, error) {
+	switch {
+	case strings.HasPrefix(sel, "/"):
+		p, err := glob.Compile(sel)
+		if err != nil {
+			return nil, err
+		}
+		return func(asset *manifest.EncodedAsset) bool { return p.Match(asset.Url) }, nil
+	case strings.HasPrefix(sel, "source:"):
+		source := strings.TrimPrefix(sel, "source:")
+		return func(asset *manifest.EncodedAsset) bool { return asset.Source == source }, nil
+	case sel == "lazy":
+		return func(asset *manifest.EncodedAsset) bool { return asset.Lazy }, nil
+	case sel == "eager":
+		return func(asset *manifest.EncodedAsset) bool { return !asset.Lazy }, nil
+	default:
+		return nil, fmt.Errorf("unknown selector %q", sel)
+	}
+}
+
+// ParseRules parses a list of rules. See ParseRule.
+func ParseRules(ss []string) ([]Rule, error) {
+	result := make([]Rule, 0, len(ss))
+	for _, s := range ss {
+		r, err := ParseRule(s)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// Policy assigns Cache-Control values to assets. Rules are evaluated in order, and
+// the first matching rule wins. If no rule matches, the asset's Class determines the
+// value. An empty value means no Cache-Control header is sent.
+type Policy struct {
+	// Default is the value for assets of ClassDefault.
+	Default string
+	// Rules override the built-in classes.
+	Rules []Rule
+}
+
+// Compile returns a copy of the policy with the selectors of its rules compiled. It
+// returns an error if a rule that was not built with ParseRule has an invalid selector.
+func (p Policy) Compile() (Policy, error) {
+	rules := make([]Rule, len(p.Rules))
+	for i, r := range p.Rules {
+		if r.match == nil {
+			var err error
+			r.match, err = matcher(r.Selector)
+			if err != nil {
+				return Policy{}, fmt.Errorf("invalid cache rule %q: %w", r.Selector+"="+r.Value, err)
+			}
+		}
+		rules[i] = r
+	}
+
+	p.Rules = rules
+	return p, nil
+}
+
+// CacheControl returns the Cache-Control value for an asset of a site.
+func (p Policy) CacheControl(site *manifest.Site, asset *manifest.EncodedAsset) string {
+	for _, r := range p.Rules {
+		if r.Match(asset) {
+			return r.Value
+		}
+	}
+
+	switch Classify(site, asset) {
+	case ClassIndex, ClassManifest:
+		return NoCache
+	case ClassHashed:
+		return Immutable
+	default:
+		return p.Default
+	}
+}
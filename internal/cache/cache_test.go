@@ -0,0 +1,81 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cache
+
+import (
+	"testing"
+
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+func TestClassify(t *testing.T) {
+	site := &manifest.Site{Index: "/index.html"}
+	tests := []struct {
+		url    string
+		source string
+		want   Class
+	}{
+		{url: "/index.html", want: ClassIndex},
+		{url: "/docs/index.html", want: ClassIndex},
+		{url: "/ngsw.json", want: ClassManifest},
+		{url: "/main.3f2a1b.js", source: "ngsw.json", want: ClassHashed},
+		{url: "/main-2QNK2WDW.js", source: "ngsw.json", want: ClassHashed},
+		{url: "/chunk-FB3YTQ4M.js", source: "ngsw.json", want: ClassHashed},
+		{url: "/styles-5INURTSO.css", source: "ngsw.json", want: ClassHashed},
+		{url: "/polyfills-FFHMD2TL.js", source: "webpack", want: ClassHashed},
+		{url: "/main-2QNK2WDW.js", source: "filesystem", want: ClassDefault},
+		{url: "/static/js/787.cf9d1234.chunk.js", source: "cra", want: ClassHashed},
+		{url: "/assets/index-BkX3a9_Z.js", source: "vite", want: ClassHashed},
+		{url: "/assets/index-BkX3a9_Z.js", source: "filesystem", want: ClassDefault},
+		{url: "/icon-facade.svg", want: ClassDefault},
+		{url: "/bg-decade.png", want: ClassDefault},
+		{url: "/icon-settings.svg", source: "filesystem", want: ClassDefault},
+		{url: "/favicon.ico", want: ClassDefault},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url+"@"+tt.source, func(t *testing.T) {
+			asset := &manifest.EncodedAsset{Url: tt.url, Source: tt.source}
+			if got := Classify(site, asset); got != tt.want {
+				t.Errorf("Classify(%s) = %s, want %s", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyCompile(t *testing.T) {
+	site := &manifest.Site{Index: "/index.html"}
+	asset := &manifest.EncodedAsset{Url: "/x/y.txt"}
+
+	p, err := Policy{Default: "default", Rules: []Rule{{Selector: "/x/**", Value: "no-store"}}}.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.CacheControl(site, asset); got != "no-store" {
+		t.Errorf("CacheControl() = %q, want %q", got, "no-store")
+	}
+
+	_, err = Policy{Rules: []Rule{{Selector: "unknown", Value: "no-store"}}}.Compile()
+	if err == nil {
+		t.Error("Compile() with an unknown selector: want error")
+	}
+
+	if (Rule{Selector: "/x/**", Value: "no-store"}).Match(asset) != true {
+		t.Error("Match() of an uncompiled rule: want true")
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package glob provides url path glob patterns.
+//
+// Patterns support the following wildcards:
+//
+//	**  matches zero or more characters, including '/'
+//	*   matches zero or more characters, excluding '/'
+//	?   matches exactly one character, excluding '/'
+//
+// A trailing "/**" also matches the directory itself (e.g. "/api/**" matches "/api").
+package glob
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a compiled glob pattern.
+type Pattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// Compile compiles a glob pattern.
+func Compile(glob string) (*Pattern, error) {
+	re, err := regexp.Compile(ToRegexp(glob))
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %q: %w", glob, err)
+	}
+	return &Pattern{glob: glob, re: re}, nil
+}
+
+// CompileAll compiles a list of glob patterns.
+func CompileAll(globs []string) ([]*Pattern, error) {
+	result := make([]*Pattern, 0, len(globs))
+	for _, g := range globs {
+		p, err := Compile(g)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// ToRegexp converts a glob pattern into an anchored regular expression.
+func ToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	suffix := ""
+	if strings.HasSuffix(glob, "/**") {
+		glob = strings.TrimSuffix(glob, "/**")
+		suffix = "(?:/.*)?"
+	}
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	sb.WriteString(suffix)
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// Match returns true if the path matches the pattern.
+func (p *Pattern) Match(path string) bool {
+	return p.re.MatchString(path)
+}
+
+// String returns the original glob pattern.
+func (p *Pattern) String() string {
+	return p.glob
+}
+
+// MatchAny returns true if the path matches any of the patterns.
+func MatchAny(patterns []*Pattern, path string) bool {
+	for _, p := range patterns {
+		if p.Match(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package load
+
+import (
+	"io/fs"
+
+	"github.com/spf13/pflag"
+
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+// Auto is the name of the loader that picks another loader based on the contents of the webroot.
+const Auto = "auto"
+
+type autoLoader struct{}
+
+// Name implements Loader.Name()
+func (autoLoader) Name() string { return Auto }
+
+// Description implements Loader.Description()
+func (autoLoader) Description() string {
+	return "detect the loader from the contents of the webroot, falling back to filesystem"
+}
+
+// Flags implements Loader.Flags()
+func (autoLoader) Flags(*pflag.FlagSet) {}
+
+// Load implements Loader.Load()
+func (autoLoader) Load(webroot fs.FS, settings Settings, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	loader, reason := Detect(webroot)
+	lg.Info(logging.NewMsgData("detected mode "+loader.Name(), detectEntry{Mode: loader.Name(), Reason: reason}))
+	return loader.Load(webroot, settings, opts, lg)
+}
+
+type detectEntry struct {
+	Mode   string `json:"mode"`
+	Reason string `json:"reason"`
+}
+
+// Detect returns the first registered Detector that recognizes webroot, and the reason it
+// was chosen. If no loader recognizes webroot, the filesystem loader is returned.
+func Detect(webroot fs.FS) (Loader, string) {
+	for _, l := range Detectors() {
+		if reason, ok := l.(Detector).Detect(webroot); ok {
+			return l, reason
+		}
+	}
+	return filesystemLoader{}, "no other loader recognized the webroot"
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ajjensen13/dayspa/internal/load/log"
+	"github.com/ajjensen13/dayspa/internal/load/shared"
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+// ManifestPath is where Create React App writes its asset manifest, relative to the webroot.
+const ManifestPath = "asset-manifest.json"
+
+type craManifest struct {
+	// Files maps logical names (e.g. "main.js") to urls, which are prefixed with the public url.
+	Files map[string]string `json:"files"`
+	// Entrypoints are the files, relative to the webroot, that are loaded by index.html.
+	Entrypoints []string `json:"entrypoints"`
+}
+
+// Loads a Create React App webroot, described by its asset-manifest.json, into a site manifest.
+func Load(fsys fs.FS, opts shared.Options, lg logging.Logger) (*manifest.Site, error) {
+	entry := log.Entry{WebRoot: log.WebRoot(fsys)}
+	defer func() { lg.Info(logging.NewMsgData("loaded asset-manifest.json", entry)) }()
+
+	var err error
+	entry.ManifestDetails, err = parseManifest(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	m := entry.ManifestDetails.Manifest.(craManifest)
+
+	result := manifest.Site{Index: "/index.html"}
+
+	result.Assets, err = loadAssets(fsys, m, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	shared.Finish(&result, &entry)
+
+	return &result, nil
+}
+
+func loadAssets(fsys fs.FS, m craManifest, opts shared.Options) (manifest.EncodedAssets, error) {
+	// First, load the entrypoints, which are needed to render the index
+	var result manifest.EncodedAssets
+	for _, file := range m.Entrypoints {
+		url := shared.Url(file)
+		if result.Contains(url) {
+			continue
+		}
+
+		asset, err := shared.EncodedAsset(fsys, url, false, "cra", opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build encoded asset from manifest %s: %w", url, err)
+		}
+
+		result = append(result, asset)
+	}
+
+	// Next, load the rest of the files from the manifest
+	publicUrl := m.publicUrl()
+	for _, name := range m.fileNames() {
+		url := shared.Url(strings.TrimPrefix(m.Files[name], publicUrl))
+		if result.Contains(url) {
+			continue
+		}
+
+		asset, err := shared.EncodedAsset(fsys, url, true, "cra", opts)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && path.Ext(url) == ".map":
+			continue // GENERATE_SOURCEMAP=false omits maps, but deploys often strip them afterwards too
+		case err != nil:
+			return nil, fmt.Errorf("failed to build encoded asset from manifest %s: %w", url, err)
+		}
+
+		result = append(result, asset)
+	}
+
+	// Finally, load files not listed in the manifest
+	err := shared.WalkFiles(fsys, func(url string) error {
+		if result.Contains(url) {
+			return nil
+		}
+
+		asset, err := shared.EncodedAsset(fsys, url, true, "filesystem", opts)
+		if err != nil {
+			return fmt.Errorf("failed to build encoded asset from file %s: %w", shared.FileName(url), err)
+		}
+
+		result = append(result, asset)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(result)
+	return result, nil
+}
+
+// fileNames returns the keys of Files, sorted so that the site is loaded in the same order every time.
+func (m craManifest) fileNames() []string {
+	result := make([]string, 0, len(m.Files))
+	for name := range m.Files {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// publicUrl returns the prefix of the urls in Files (i.e. the app's "homepage"), by
+// finding the url of an entrypoint.
+func (m craManifest) publicUrl() string {
+	for _, file := range m.Entrypoints {
+		for _, url := range m.Files {
+			if strings.HasSuffix(url, "/"+file) {
+				return strings.TrimSuffix(url, file)
+			}
+		}
+	}
+	return "/"
+}
+
+func parseManifest(fsys fs.FS) (result log.ManifestDetails, err error) {
+	result.Path = ManifestPath
+
+	var f fs.File
+	f, err = fsys.Open(result.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var m craManifest
+	err = json.NewDecoder(f).Decode(&m)
+	if err != nil {
+		return
+	}
+
+	result.Manifest = m
+	return
+}
@@ -18,76 +18,44 @@
 package filesystem
 
 import (
-	"crypto/sha256"
-	"encoding/base64"
 	"fmt"
-	"github.com/ajjensen13/gke"
-	"os"
-	"path"
-	"path/filepath"
+	"io/fs"
 	"sort"
-	"strings"
 
 	"github.com/ajjensen13/dayspa/internal/load/log"
 	"github.com/ajjensen13/dayspa/internal/load/shared"
+	"github.com/ajjensen13/dayspa/internal/logging"
 	"github.com/ajjensen13/dayspa/internal/manifest"
 )
 
 // Loads filesystem based webroot into a site manifest.
-func Load(webroot string, lg gke.Logger) (*manifest.Site, error) {
-	entry := log.Entry{WebRoot: webroot}
-	defer func() { lg.Info(gke.NewMsgData("loaded filesystem", entry)) }()
+func Load(fsys fs.FS, opts shared.Options, lg logging.Logger) (*manifest.Site, error) {
+	entry := log.Entry{WebRoot: log.WebRoot(fsys)}
+	defer func() { lg.Info(logging.NewMsgData("loaded filesystem", entry)) }()
 
 	result := manifest.Site{Index: "/index.html"}
 
 	var err error
-	result.Assets, err = loadAssets(webroot)
+	result.Assets, err = loadAssets(fsys, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	c := sha256.New()
-	for _, asset := range result.Assets {
-		c.Write([]byte(asset.Etag))
-		entry.SiteDetails.Assets = append(entry.SiteDetails.Assets, fmt.Sprintf("%s@%s %s", asset.File, asset.Etag, asset.ContentType))
-	}
-
-	result.Checksum = base64.StdEncoding.EncodeToString(c.Sum(nil))
-
-	entry.SiteDetails.Index = result.Index
-	entry.SiteDetails.Checksum = result.Checksum
+	shared.Finish(&result, &entry)
 
 	return &result, nil
 }
 
-func loadAssets(webroot string) (manifest.EncodedAssets, error) {
+func loadAssets(fsys fs.FS, opts shared.Options) (manifest.EncodedAssets, error) {
 	var result manifest.EncodedAssets
-	err := filepath.Walk(webroot, func(fpath string, info os.FileInfo, err error) error {
-		switch {
-		case err != nil:
-			return err
-		case info.IsDir():
-			return nil
-		case strings.HasPrefix(filepath.Base(fpath), "."):
-			return nil
-		case strings.HasPrefix(filepath.Base(fpath), "_"):
-			return nil
-		}
-
-		rfp, err := filepath.Rel(webroot, fpath)
-		if err != nil {
-			return fmt.Errorf("failed to determine relative path to file %s from webroot %s: %w", fpath, webroot, err)
-		}
-
-		url := path.Join("/", filepath.ToSlash(rfp))
-
+	err := shared.WalkFiles(fsys, func(url string) error {
 		if result.Contains(url) {
 			return nil
 		}
 
-		asset, err := shared.EncodedAsset(webroot, url, true, "filesystem")
+		asset, err := shared.EncodedAsset(fsys, url, true, "filesystem", opts)
 		if err != nil {
-			return fmt.Errorf("failed to build encoded asset from file %s: %w", fpath, err)
+			return fmt.Errorf("failed to build encoded asset from file %s: %w", shared.FileName(url), err)
 		}
 
 		result = append(result, asset)
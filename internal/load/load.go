@@ -18,19 +18,208 @@
 package load
 
 import (
-	"github.com/ajjensen13/gke"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
 
+	"github.com/spf13/pflag"
+
+	"github.com/ajjensen13/dayspa/internal/load/cra"
 	"github.com/ajjensen13/dayspa/internal/load/filesystem"
 	"github.com/ajjensen13/dayspa/internal/load/ngsw"
+	"github.com/ajjensen13/dayspa/internal/load/shared"
+	"github.com/ajjensen13/dayspa/internal/load/vite"
+	"github.com/ajjensen13/dayspa/internal/load/webpack"
+	"github.com/ajjensen13/dayspa/internal/logging"
 	"github.com/ajjensen13/dayspa/internal/manifest"
 )
 
-// Ngsw loads an ngsw.json based webroot into a site manifest.
-func Ngsw(webroot string, lg gke.Logger) (*manifest.Site, error) {
-	return ngsw.Load(webroot, lg)
+// Options configures how assets are encoded.
+type Options = shared.Options
+
+// Dir returns the file system of the directory dir, like os.DirFS. It is described
+// by dir in the load log entry.
+func Dir(dir string) fs.FS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+// String implements fmt.Stringer.String()
+func (d dirFS) String() string {
+	return d.dir
+}
+
+// Ngsw loads an ngsw.json based webroot into a site manifest. Files are verified against
+// the hashTable of ngsw.json, as determined by verify.
+func Ngsw(webroot fs.FS, verify ngsw.Verify, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	return ngsw.Load(webroot, verify, opts, lg)
+}
+
+// Vite loads a Vite webroot, described by its build manifest, into a site manifest.
+func Vite(webroot fs.FS, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	return vite.Load(webroot, opts, lg)
+}
+
+// Cra loads a Create React App webroot, described by its asset-manifest.json, into a site manifest.
+func Cra(webroot fs.FS, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	return cra.Load(webroot, opts, lg)
+}
+
+// Webpack loads a webpack webroot, described by its stats.json or webpack-assets-manifest output,
+// into a site manifest. If manifestPath is empty, the default locations are tried.
+func Webpack(webroot fs.FS, manifestPath string, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	return webpack.Load(webroot, manifestPath, opts, lg)
 }
 
 // Filesystem loads a filesystem based webroot into a site manifest.
-func Filesystem(webroot string, lg gke.Logger) (*manifest.Site, error) {
-	return filesystem.Load(webroot, lg)
+func Filesystem(webroot fs.FS, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	return filesystem.Load(webroot, opts, lg)
+}
+
+func init() {
+	Register(autoLoader{})
+	Register(ngswLoader{})
+	Register(viteLoader{})
+	Register(craLoader{})
+	Register(webpackLoader{})
+	Register(filesystemLoader{})
+}
+
+type ngswLoader struct{}
+
+// Name implements Loader.Name()
+func (ngswLoader) Name() string { return "ngsw" }
+
+// Description implements Loader.Description()
+func (ngswLoader) Description() string {
+	return "Angular service worker builds, described by ngsw.json"
+}
+
+// Flags implements Loader.Flags()
+func (ngswLoader) Flags(flags *pflag.FlagSet) {
+	flags.String("ngsw-verify-hashes", string(ngsw.VerifyFail), fmt.Sprintf("what to do when files do not match the hashTable of ngsw.json (one of: %v)", ngsw.Verifies))
+}
+
+// Detect implements Detector.Detect()
+func (ngswLoader) Detect(webroot fs.FS) (string, bool) {
+	return "found ngsw.json", exists(webroot, "ngsw.json")
+}
+
+// Load implements Loader.Load()
+func (ngswLoader) Load(webroot fs.FS, settings Settings, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	verify := ngsw.VerifyFail
+	if settings != nil {
+		var err error
+		verify, err = ngsw.ParseVerify(settings.GetString("ngsw-verify-hashes"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return Ngsw(webroot, verify, opts, lg)
+}
+
+type viteLoader struct{}
+
+// Name implements Loader.Name()
+func (viteLoader) Name() string { return "vite" }
+
+// Description implements Loader.Description()
+func (viteLoader) Description() string {
+	return "Vite builds, described by .vite/manifest.json (build.manifest must be enabled)"
+}
+
+// Flags implements Loader.Flags()
+func (viteLoader) Flags(*pflag.FlagSet) {}
+
+// Detect implements Detector.Detect()
+func (viteLoader) Detect(webroot fs.FS) (string, bool) {
+	p, ok := vite.Find(webroot)
+	return "found " + p, ok
+}
+
+// Load implements Loader.Load()
+func (viteLoader) Load(webroot fs.FS, _ Settings, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	return Vite(webroot, opts, lg)
+}
+
+type craLoader struct{}
+
+// Name implements Loader.Name()
+func (craLoader) Name() string { return "cra" }
+
+// Description implements Loader.Description()
+func (craLoader) Description() string {
+	return "Create React App builds, described by asset-manifest.json"
+}
+
+// Flags implements Loader.Flags()
+func (craLoader) Flags(*pflag.FlagSet) {}
+
+// Detect implements Detector.Detect()
+func (craLoader) Detect(webroot fs.FS) (string, bool) {
+	return "found " + cra.ManifestPath, exists(webroot, cra.ManifestPath)
+}
+
+// Load implements Loader.Load()
+func (craLoader) Load(webroot fs.FS, _ Settings, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	return Cra(webroot, opts, lg)
+}
+
+type webpackLoader struct{}
+
+// Name implements Loader.Name()
+func (webpackLoader) Name() string { return "webpack" }
+
+// Description implements Loader.Description()
+func (webpackLoader) Description() string {
+	return "webpack builds, described by stats.json or webpack-assets-manifest output"
+}
+
+// Flags implements Loader.Flags()
+func (webpackLoader) Flags(flags *pflag.FlagSet) {
+	flags.String("webpack-manifest", "", fmt.Sprintf("path to the webpack manifest, relative to the webroot (default one of: %s)", strings.Join(webpack.ManifestPaths, ", ")))
+}
+
+// Detect implements Detector.Detect()
+func (webpackLoader) Detect(webroot fs.FS) (string, bool) {
+	p, ok := webpack.Find(webroot)
+	return "found " + p, ok
+}
+
+// Load implements Loader.Load()
+func (webpackLoader) Load(webroot fs.FS, settings Settings, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	var manifestPath string
+	if settings != nil {
+		manifestPath = settings.GetString("webpack-manifest")
+	}
+	return Webpack(webroot, manifestPath, opts, lg)
+}
+
+type filesystemLoader struct{}
+
+// Name implements Loader.Name()
+func (filesystemLoader) Name() string { return "filesystem" }
+
+// Description implements Loader.Description()
+func (filesystemLoader) Description() string {
+	return "every file in the webroot, served with /index.html as the index"
+}
+
+// Flags implements Loader.Flags()
+func (filesystemLoader) Flags(*pflag.FlagSet) {}
+
+// Load implements Loader.Load()
+func (filesystemLoader) Load(webroot fs.FS, _ Settings, opts Options, lg logging.Logger) (*manifest.Site, error) {
+	return Filesystem(webroot, opts, lg)
+}
+
+// exists returns true if name is a regular file in webroot.
+func exists(webroot fs.FS, name string) bool {
+	fi, err := fs.Stat(webroot, name)
+	return err == nil && fi.Mode().IsRegular()
 }
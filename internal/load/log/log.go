@@ -17,10 +17,16 @@
 
 package log
 
+import (
+	"fmt"
+	"io/fs"
+)
+
 type Entry struct {
 	WebRoot         string          `json:"web_root"`
 	ManifestDetails ManifestDetails `json:"manifest_details"`
 	SiteDetails     SiteDetails     `json:"site_details"`
+	HashDetails     *HashDetails    `json:"hash_details,omitempty"`
 }
 
 type ManifestDetails struct {
@@ -33,3 +39,19 @@ type SiteDetails struct {
 	Assets   []string
 	Checksum string
 }
+
+// HashDetails is the result of verifying files against the hashes in a manifest.
+type HashDetails struct {
+	Verified   int
+	Mismatched []string
+	Missing    []string
+}
+
+// WebRoot describes fsys for logging. File systems that implement fmt.Stringer (such as
+// those returned by load.Dir) describe themselves; others are described by their type.
+func WebRoot(fsys fs.FS) string {
+	if s, ok := fsys.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", fsys)
+}
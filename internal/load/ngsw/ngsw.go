@@ -18,27 +18,29 @@
 package ngsw
 
 import (
-	"crypto/sha256"
-	"encoding/base64"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"github.com/ajjensen13/gke"
-	"os"
+	"io/fs"
 	"path"
-	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 
 	"github.com/ajjensen13/dayspa/internal/load/log"
 	"github.com/ajjensen13/dayspa/internal/load/shared"
+	"github.com/ajjensen13/dayspa/internal/logging"
 	"github.com/ajjensen13/dayspa/internal/manifest"
 )
 
 type ngswManifest struct {
-	ConfigVersion uint32           `json:"configVersion"`
-	Timestamp     uint64           `json:"timestamp"`
-	Index         string           `json:"index"`
-	AssetGroups   []ngswAssetGroup `json:"assetGroups"`
+	ConfigVersion  uint32              `json:"configVersion"`
+	Timestamp      uint64              `json:"timestamp"`
+	Index          string              `json:"index"`
+	AssetGroups    []ngswAssetGroup    `json:"assetGroups"`
+	NavigationUrls []ngswNavigationUrl `json:"navigationUrls"`
+	HashTable      map[string]string   `json:"hashTable"`
 }
 
 type ngswAssetGroup struct {
@@ -49,13 +51,46 @@ type ngswAssetGroup struct {
 	Patterns    []string `json:"patterns"`
 }
 
-// Loads an ngsw.json based webroot into a site manifest.
-func Load(webroot string, lg gke.Logger) (*manifest.Site, error) {
-	entry := log.Entry{WebRoot: webroot}
-	defer func() { lg.Info(gke.NewMsgData("loaded ngsw.json", entry)) }()
+type ngswNavigationUrl struct {
+	Positive bool   `json:"positive"`
+	Regex    string `json:"regex"`
+}
+
+// Verify determines what happens when files do not match the hashTable of ngsw.json.
+// The service worker refuses to install a version whose files do not match their hashes,
+// which leaves clients stuck in an unrecoverable state.
+type Verify string
+
+const (
+	// VerifyFail fails to load the site.
+	VerifyFail Verify = "fail"
+	// VerifyWarn logs a warning, and loads the site.
+	VerifyWarn Verify = "warn"
+	// VerifyOff skips verification.
+	VerifyOff Verify = "off"
+)
+
+// Verifies lists every Verify value.
+var Verifies = []Verify{VerifyFail, VerifyWarn, VerifyOff}
+
+// ParseVerify parses s (e.g. "warn") into a Verify.
+func ParseVerify(s string) (Verify, error) {
+	for _, v := range Verifies {
+		if string(v) == s {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported hash verification: %q (one of: %v)", s, Verifies)
+}
+
+// Loads an ngsw.json based webroot into a site manifest. Files are verified against the
+// hashTable of ngsw.json, as determined by verify.
+func Load(fsys fs.FS, verify Verify, opts shared.Options, lg logging.Logger) (*manifest.Site, error) {
+	entry := log.Entry{WebRoot: log.WebRoot(fsys)}
+	defer func() { lg.Info(logging.NewMsgData("loaded ngsw.json", entry)) }()
 
 	var err error
-	entry.ManifestDetails, err = parseManifest(webroot)
+	entry.ManifestDetails, err = parseManifest(fsys)
 	if err != nil {
 		return nil, err
 	}
@@ -64,70 +99,120 @@ func Load(webroot string, lg gke.Logger) (*manifest.Site, error) {
 
 	result := manifest.Site{Index: m.Index}
 
-	result.Assets, err = loadAssets(webroot, m.AssetGroups)
+	result.NavigationUrls, err = navigationUrls(m.NavigationUrls)
 	if err != nil {
 		return nil, err
 	}
 
-	c := sha256.New()
-	for _, asset := range result.Assets {
-		c.Write([]byte(asset.Etag))
-		entry.SiteDetails.Assets = append(entry.SiteDetails.Assets, fmt.Sprintf("%s@%s %s", asset.File, asset.Etag, asset.ContentType))
+	result.Assets, err = loadAssets(fsys, m.AssetGroups, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	result.Checksum = base64.StdEncoding.EncodeToString(c.Sum(nil))
+	shared.Finish(&result, &entry)
 
-	entry.SiteDetails.Index = result.Index
-	entry.SiteDetails.Checksum = result.Checksum
+	if verify == VerifyOff {
+		return &result, nil
+	}
+
+	hd := verifyHashes(result.Assets, m.HashTable)
+	entry.HashDetails = &hd
+	if len(hd.Mismatched) == 0 && len(hd.Missing) == 0 {
+		return &result, nil
+	}
+
+	err = fmt.Errorf("files do not match the hashTable of ngsw.json (mismatched: %v, missing: %v)", hd.Mismatched, hd.Missing)
+	if verify == VerifyFail {
+		return nil, err
+	}
+	lg.WarningErr(err)
 
 	return &result, nil
 }
 
-func loadAssets(webroot string, assets []ngswAssetGroup) (manifest.EncodedAssets, error) {
+// verifyHashes compares the SHA-1 hash of each file listed in hashTable with its hash in hashTable.
+func verifyHashes(assets manifest.EncodedAssets, hashTable map[string]string) (result log.HashDetails) {
+	urls := make([]string, 0, len(hashTable))
+	for url := range hashTable {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	for _, url := range urls {
+		asset, ok := findAsset(assets, path.Clean(url))
+		if !ok {
+			result.Missing = append(result.Missing, url)
+			continue
+		}
+
+		hash := sha1.Sum(identityData(asset))
+		if !strings.EqualFold(hex.EncodeToString(hash[:]), hashTable[url]) {
+			result.Mismatched = append(result.Mismatched, url)
+			continue
+		}
+
+		result.Verified++
+	}
+	return
+}
+
+func identityData(asset *manifest.EncodedAsset) []byte {
+	for _, datum := range asset.Data {
+		if datum.ContentEncoding == manifest.Identity {
+			return datum.Data
+		}
+	}
+	return nil
+}
+
+func findAsset(assets manifest.EncodedAssets, url string) (*manifest.EncodedAsset, bool) {
+	for _, asset := range assets {
+		if asset.Url == url {
+			return asset, true
+		}
+	}
+	return nil, false
+}
+
+func loadAssets(fsys fs.FS, assets []ngswAssetGroup, opts shared.Options) (manifest.EncodedAssets, error) {
+	groups, err := compileAssetGroups(assets)
+	if err != nil {
+		return nil, err
+	}
+
 	// First, load files from the manifest
 	var result manifest.EncodedAssets
-	for _, a := range assets {
-		for _, url := range a.Urls {
+	for _, g := range groups {
+		for _, url := range g.Urls {
 			url = path.Clean(url) // use consistent cleaning with assets from manifest and from filesystem
 
-			lazy := a.InstallMode == "lazy"
-			asset, err := shared.EncodedAsset(webroot, url, lazy, "ngsw.json")
+			asset, err := shared.EncodedAsset(fsys, url, g.lazy(), "ngsw.json", opts)
 			if err != nil {
 				return nil, fmt.Errorf("failed to build encoded asset from manifest %s: %w", url, err)
 			}
+			asset.Group = g.Name
 
 			result = append(result, asset)
 		}
 	}
 
-	// Next, load files not listed in the manifest
-	err := filepath.Walk(webroot, func(fpath string, info os.FileInfo, err error) error {
-		switch {
-		case err != nil:
-			return err
-		case info.IsDir():
-			return nil
-		case strings.HasPrefix(filepath.Base(fpath), "."):
-			return nil
-		case strings.HasPrefix(filepath.Base(fpath), "_"):
+	// Next, load files not listed in the manifest. Files matching an asset group's patterns
+	// belong to the first such group, like they do in the service worker.
+	err = shared.WalkFiles(fsys, func(url string) error {
+		if result.Contains(url) {
 			return nil
 		}
 
-		rfp, err := filepath.Rel(webroot, fpath)
-		if err != nil {
-			return fmt.Errorf("failed to determine relative path to file %s from webroot %s: %w", fpath, webroot, err)
+		lazy, source, group := true, "filesystem", "" // anything not in the manifest is assumed to be lazy-loaded
+		if g, ok := groups.match(url); ok {
+			lazy, source, group = g.lazy(), "ngsw.json", g.Name
 		}
 
-		url := path.Join("/", filepath.ToSlash(rfp))
-
-		if result.Contains(url) {
-			return nil
-		}
-
-		asset, err := shared.EncodedAsset(webroot, url, true, "filesystem") // anything not in the manifest is assumed to be lazy-loaded
+		asset, err := shared.EncodedAsset(fsys, url, lazy, source, opts)
 		if err != nil {
-			return fmt.Errorf("failed to build encoded asset from file %s: %w", fpath, err)
+			return fmt.Errorf("failed to build encoded asset from file %s: %w", shared.FileName(url), err)
 		}
+		asset.Group = group
 
 		result = append(result, asset)
 		return nil
@@ -141,16 +226,74 @@ func loadAssets(webroot string, assets []ngswAssetGroup) (manifest.EncodedAssets
 	return result, nil
 }
 
-func parseManifest(webroot string) (result log.ManifestDetails, err error) {
-	result.Path = filepath.Join(webroot, "ngsw.json")
+// assetGroup is an ngswAssetGroup with its patterns compiled.
+type assetGroup struct {
+	ngswAssetGroup
+	patterns []*regexp.Regexp
+}
+
+type assetGroups []assetGroup
 
-	var f *os.File
-	f, err = os.Open(result.Path)
+func compileAssetGroups(groups []ngswAssetGroup) (assetGroups, error) {
+	result := make(assetGroups, 0, len(groups))
+	for _, g := range groups {
+		ag := assetGroup{ngswAssetGroup: g}
+		for _, p := range g.Patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile pattern %q of asset group %s: %w", p, g.Name, err)
+			}
+			ag.patterns = append(ag.patterns, re)
+		}
+		result = append(result, ag)
+	}
+	return result, nil
+}
+
+// lazy returns true if the group's files are only fetched by the service worker when they are requested.
+func (g assetGroup) lazy() bool {
+	return g.InstallMode == "lazy"
+}
+
+// match returns the first group with a pattern that matches url.
+func (gs assetGroups) match(url string) (assetGroup, bool) {
+	for _, g := range gs {
+		for _, re := range g.patterns {
+			if re.MatchString(url) {
+				return g, true
+			}
+		}
+	}
+	return assetGroup{}, false
+}
+
+func navigationUrls(urls []ngswNavigationUrl) ([]manifest.NavigationUrl, error) {
+	result := make([]manifest.NavigationUrl, 0, len(urls))
+	for _, u := range urls {
+		if _, err := regexp.Compile(u.Regex); err != nil {
+			return nil, fmt.Errorf("failed to compile navigation url pattern %q: %w", u.Regex, err)
+		}
+		result = append(result, manifest.NavigationUrl{Positive: u.Positive, Regex: u.Regex})
+	}
+	return result, nil
+}
+
+func parseManifest(fsys fs.FS) (result log.ManifestDetails, err error) {
+	result.Path = "ngsw.json"
+
+	var f fs.File
+	f, err = fsys.Open(result.Path)
 	if err != nil {
 		return
 	}
 	defer f.Close()
 
-	err = json.NewDecoder(f).Decode(&result.Manifest)
+	var m ngswManifest
+	err = json.NewDecoder(f).Decode(&m)
+	if err != nil {
+		return
+	}
+
+	result.Manifest = m
 	return
 }
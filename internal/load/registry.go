@@ -0,0 +1,131 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package load
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+// Loader loads a webroot into a site manifest. Each loader is selected with --mode=<Name>.
+type Loader interface {
+	// Name is the value of --mode that selects the loader.
+	Name() string
+	// Description is a short, one line description of the loader, used in help text.
+	Description() string
+	// Flags adds flags specific to the loader. Flag names should be prefixed with the
+	// loader's name (e.g. --ngsw-foo), since every loader's flags are added to the command.
+	Flags(flags *pflag.FlagSet)
+	// Load loads the webroot. The values of the loader's flags are read from settings.
+	Load(webroot fs.FS, settings Settings, opts Options, lg logging.Logger) (*manifest.Site, error)
+}
+
+// Detector is implemented by loaders that can recognize webroots built for them.
+// It is used by the auto loader to pick a loader.
+type Detector interface {
+	// Detect returns true, and a short human readable reason, if webroot looks like it
+	// should be loaded by the loader.
+	Detect(webroot fs.FS) (reason string, ok bool)
+}
+
+// Settings provides the values of flags, after config files and environment variables
+// have been applied. *viper.Viper implements Settings. Settings may be nil, in which
+// case loaders use the defaults of their flags.
+type Settings interface {
+	GetBool(key string) bool
+	GetInt(key string) int
+	GetString(key string) string
+	GetStringSlice(key string) []string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Loader)
+	// registered is the names of the loaders, in the order they were registered.
+	registered []string
+)
+
+// Register makes a loader available by its name. It panics if a loader with the
+// same name has already been registered.
+func Register(l Loader) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name := l.Name()
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("load: Register called twice for loader %s", name))
+	}
+	registry[name] = l
+	registered = append(registered, name)
+}
+
+// Lookup returns the loader registered with name.
+func Lookup(name string) (Loader, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	l, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported mode: %q (one of: %s)", name, strings.Join(names(), ", "))
+	}
+	return l, nil
+}
+
+// Loaders returns every registered loader, sorted by name.
+func Loaders() []Loader {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	result := make([]Loader, 0, len(registry))
+	for _, name := range names() {
+		result = append(result, registry[name])
+	}
+	return result
+}
+
+// Detectors returns every registered loader that implements Detector, in the order
+// they were registered. Loaders registered earlier take precedence.
+func Detectors() []Loader {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var result []Loader
+	for _, name := range registered {
+		if _, ok := registry[name].(Detector); ok {
+			result = append(result, registry[name])
+		}
+	}
+	return result
+}
+
+func names() []string {
+	result := make([]string, 0, len(registry))
+	for name := range registry {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -23,19 +23,23 @@ import (
 	"compress/gzip"
 	"crypto/sha256"
 	"encoding/base64"
-	"io/ioutil"
+	"fmt"
+	"io/fs"
 	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
 	"sort"
 	"strings"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/ajjensen13/dayspa/internal/load/log"
 	"github.com/ajjensen13/dayspa/internal/manifest"
 )
 
-func identityEncoded(fpath string) (*manifest.EncodedDatum, error) {
-	data, err := ioutil.ReadFile(fpath)
+func identityEncoded(fsys fs.FS, name string) (*manifest.EncodedDatum, error) {
+	data, err := fs.ReadFile(fsys, name)
 	if err != nil {
 		return nil, err
 	}
@@ -82,29 +86,93 @@ func flateEncoded(raw []byte) (*manifest.EncodedDatum, error) {
 	return &manifest.EncodedDatum{ContentEncoding: manifest.Deflate, Data: buf.Bytes()}, nil
 }
 
-func calculateETag(raw []byte) string {
-	hash := sha256.Sum256(raw)
-	return base64.StdEncoding.EncodeToString(hash[:])
+func brotliEncoded(raw []byte) (*manifest.EncodedDatum, error) {
+	var buf bytes.Buffer
+	br := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+
+	_, err := br.Write(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	err = br.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifest.EncodedDatum{ContentEncoding: manifest.Brotli, Data: buf.Bytes()}, nil
+}
+
+func zstdEncoded(raw []byte) (*manifest.EncodedDatum, error) {
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = zw.Write(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifest.EncodedDatum{ContentEncoding: manifest.Zstd, Data: buf.Bytes()}, nil
+}
+
+// Options configures how assets are encoded.
+type Options struct {
+	// ETagLength is the number of characters of the encoded hash used in entity tags.
+	// Zero (or a length longer than the hash) uses the full hash.
+	ETagLength int
 }
 
-func EncodedAsset(webroot, url string, lazy bool, source string) (*manifest.EncodedAsset, error) {
-	fpath := filepath.FromSlash(url)
-	fpath = filepath.Join(webroot, url)
+// calculateETag returns a strong entity tag for a representation.
+// See: https://www.rfc-editor.org/rfc/rfc9110#name-etag
+func calculateETag(data []byte, length int) string {
+	hash := sha256.Sum256(data)
+	tag := base64.RawURLEncoding.EncodeToString(hash[:])
+	if length > 0 && length < len(tag) {
+		tag = tag[:length]
+	}
+	return `"` + tag + `"`
+}
+
+// FileName returns the name of the file in a webroot that is served at url.
+func FileName(url string) string {
+	name := strings.TrimPrefix(path.Clean("/"+url), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// Url returns the url that the file name, relative to a webroot, is served at.
+func Url(name string) string {
+	return path.Join("/", name)
+}
 
-	fi, err := os.Stat(fpath)
+// EncodedAsset loads the file at url, relative to the root of fsys, and encodes it with every supported content encoding.
+func EncodedAsset(fsys fs.FS, url string, lazy bool, source string, opts Options) (*manifest.EncodedAsset, error) {
+	name := FileName(url)
+
+	fi, err := fs.Stat(fsys, name)
 	if err != nil {
 		return nil, err
 	}
 
 	result := manifest.EncodedAsset{
 		Url:     url,
-		File:    fpath,
+		File:    name,
 		Lazy:    lazy,
 		Source:  source,
 		ModTime: fi.ModTime(),
 	}
 
-	raw, err := identityEncoded(fpath)
+	raw, err := identityEncoded(fsys, name)
 	if err != nil {
 		return nil, err
 	}
@@ -122,18 +190,35 @@ func EncodedAsset(webroot, url string, lazy bool, source string) (*manifest.Enco
 	}
 	result.Data = append(result.Data, fl)
 
-	result.ContentType = determineContentType(fpath, raw.Data)
-	result.Etag = calculateETag(raw.Data)
+	br, err := brotliEncoded(raw.Data)
+	if err != nil {
+		return nil, err
+	}
+	result.Data = append(result.Data, br)
+
+	zs, err := zstdEncoded(raw.Data)
+	if err != nil {
+		return nil, err
+	}
+	result.Data = append(result.Data, zs)
+
+	// Each representation gets its own strong entity tag, since they are not byte-for-byte identical.
+	for _, datum := range result.Data {
+		datum.Etag = calculateETag(datum.Data, opts.ETagLength)
+	}
+
+	result.ContentType = determineContentType(name, raw.Data)
+	result.Etag = raw.Etag
 
 	sort.Sort(result.Data)
 
 	return &result, nil
 }
 
-func determineContentType(fpath string, data []byte) manifest.ContentType {
+func determineContentType(name string, data []byte) manifest.ContentType {
 	result := ""
 
-	if ext := filepath.Ext(fpath); ext != "" {
+	if ext := path.Ext(name); ext != "" {
 		result = mime.TypeByExtension(ext)
 	}
 
@@ -152,3 +237,46 @@ func determineContentType(fpath string, data []byte) manifest.ContentType {
 
 	return manifest.ContentType(result)
 }
+
+// Finish calculates the checksum of a loaded site, and records the site in the load log entry.
+func Finish(site *manifest.Site, entry *log.Entry) {
+	c := sha256.New()
+	for _, asset := range site.Assets {
+		c.Write([]byte(asset.Etag))
+		entry.SiteDetails.Assets = append(entry.SiteDetails.Assets, fmt.Sprintf("%s@%s %s", asset.File, asset.Etag, asset.ContentType))
+	}
+
+	site.Checksum = base64.StdEncoding.EncodeToString(c.Sum(nil))
+
+	entry.SiteDetails.Index = site.Index
+	entry.SiteDetails.Checksum = site.Checksum
+}
+
+// WalkFiles calls fn with the url of every file in fsys that can be served.
+// Hidden files and directories (prefixed with "." or "_") are skipped, so that build
+// metadata such as .vite/manifest.json is not served. The .well-known directory
+// (RFC 8615) is not hidden.
+func WalkFiles(fsys fs.FS, fn func(url string) error) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case name == ".":
+			return nil
+		case d.IsDir() && d.Name() == ".well-known":
+			return nil
+		case d.IsDir() && hidden(d.Name()):
+			return fs.SkipDir
+		case d.IsDir():
+			return nil
+		case hidden(d.Name()):
+			return nil
+		}
+
+		return fn(Url(name))
+	})
+}
+
+func hidden(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
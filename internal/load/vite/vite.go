@@ -0,0 +1,229 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package vite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/ajjensen13/dayspa/internal/load/log"
+	"github.com/ajjensen13/dayspa/internal/load/shared"
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+// manifestPaths are where Vite writes its build manifest, relative to the webroot.
+// Vite 5 writes .vite/manifest.json; earlier versions wrote manifest.json.
+var manifestPaths = []string{".vite/manifest.json", "manifest.json"}
+
+// viteManifest maps chunk keys (usually the source file) to chunks.
+// See: https://vitejs.dev/guide/backend-integration.html
+type viteManifest map[string]viteChunk
+
+type viteChunk struct {
+	File           string   `json:"file"`
+	Src            string   `json:"src"`
+	IsEntry        bool     `json:"isEntry"`
+	IsDynamicEntry bool     `json:"isDynamicEntry"`
+	Imports        []string `json:"imports"`
+	DynamicImports []string `json:"dynamicImports"`
+	Css            []string `json:"css"`
+	Assets         []string `json:"assets"`
+}
+
+// Find returns the path to the Vite manifest in webroot, if there is one.
+func Find(webroot fs.FS) (string, bool) {
+	for _, p := range manifestPaths {
+		if _, err := readManifest(webroot, p); err == nil {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Loads a Vite webroot, described by its build manifest, into a site manifest.
+func Load(fsys fs.FS, opts shared.Options, lg logging.Logger) (*manifest.Site, error) {
+	entry := log.Entry{WebRoot: log.WebRoot(fsys)}
+	defer func() { lg.Info(logging.NewMsgData("loaded vite manifest", entry)) }()
+
+	var err error
+	entry.ManifestDetails, err = parseManifest(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	m := entry.ManifestDetails.Manifest.(viteManifest)
+
+	result := manifest.Site{Index: "/index.html"}
+
+	result.Assets, err = loadAssets(fsys, m, entry.ManifestDetails.Path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	shared.Finish(&result, &entry)
+
+	return &result, nil
+}
+
+func loadAssets(fsys fs.FS, m viteManifest, manifestPath string, opts shared.Options) (manifest.EncodedAssets, error) {
+	// First, load the files needed by the entry chunks. Dynamic imports are left to be
+	// loaded lazily, along with the rest of the webroot.
+	var result manifest.EncodedAssets
+	for _, file := range m.eagerFiles() {
+		url := shared.Url(file)
+		if result.Contains(url) {
+			continue
+		}
+
+		asset, err := shared.EncodedAsset(fsys, url, false, "vite", opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build encoded asset from manifest %s: %w", url, err)
+		}
+
+		result = append(result, asset)
+	}
+
+	// Next, load everything else, except the build manifest, which is not part of the site
+	err := shared.WalkFiles(fsys, func(url string) error {
+		if result.Contains(url) || shared.FileName(url) == manifestPath {
+			return nil
+		}
+
+		source := "filesystem"
+		if m.containsFile(shared.FileName(url)) {
+			source = "vite"
+		}
+
+		asset, err := shared.EncodedAsset(fsys, url, true, source, opts)
+		if err != nil {
+			return fmt.Errorf("failed to build encoded asset from file %s: %w", shared.FileName(url), err)
+		}
+
+		result = append(result, asset)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(result)
+	return result, nil
+}
+
+// eagerFiles returns the files of every entry chunk, and the chunks and stylesheets they
+// statically import, transitively.
+func (m viteManifest) eagerFiles() []string {
+	var result []string
+	visited := make(map[string]bool)
+
+	var visit func(key string)
+	visit = func(key string) {
+		if visited[key] {
+			return
+		}
+		visited[key] = true
+
+		chunk, ok := m[key]
+		if !ok {
+			return
+		}
+
+		result = append(result, chunk.File)
+		result = append(result, chunk.Css...)
+		for _, imp := range chunk.Imports {
+			visit(imp)
+		}
+	}
+
+	// Sort the keys so that the site is loaded in the same order every time.
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if m[key].IsEntry {
+			visit(key)
+		}
+	}
+	return result
+}
+
+// containsFile returns true if name is the output of a chunk, or one of its stylesheets or assets.
+func (m viteManifest) containsFile(name string) bool {
+	for _, chunk := range m {
+		if chunk.File == name {
+			return true
+		}
+		for _, f := range chunk.Css {
+			if f == name {
+				return true
+			}
+		}
+		for _, f := range chunk.Assets {
+			if f == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseManifest(fsys fs.FS) (result log.ManifestDetails, err error) {
+	for _, p := range manifestPaths {
+		var m viteManifest
+		m, err = readManifest(fsys, p)
+		if err == nil {
+			result.Path = p
+			result.Manifest = m
+			return
+		}
+	}
+
+	err = fmt.Errorf("failed to find a vite manifest (one of: %v): %w", manifestPaths, err)
+	return
+}
+
+// readManifest reads the Vite manifest at name. Since manifest.json is also a common name
+// for web app manifests, the manifest must have at least one entry chunk.
+func readManifest(fsys fs.FS, name string) (viteManifest, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var m viteManifest
+	err = json.NewDecoder(f).Decode(&m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vite manifest %s: %w", name, err)
+	}
+
+	for _, chunk := range m {
+		if chunk.IsEntry && chunk.File != "" {
+			return m, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s is not a vite manifest: it has no entry chunks", name)
+}
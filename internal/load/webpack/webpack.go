@@ -0,0 +1,442 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package webpack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+
+	"github.com/ajjensen13/dayspa/internal/load/log"
+	"github.com/ajjensen13/dayspa/internal/load/shared"
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+// ManifestPaths are where the manifest is looked for, relative to the webroot, if no path is given.
+// stats.json is written by `webpack --json`, and assets-manifest.json by webpack-assets-manifest.
+var ManifestPaths = []string{"stats.json", "assets-manifest.json"}
+
+// Manifest formats
+const (
+	FormatStats          = "stats"
+	FormatAssetsManifest = "webpack-assets-manifest"
+)
+
+// webpackManifest is a stats.json or webpack-assets-manifest output, reduced to the files that are
+// needed by the entrypoints, and the files that are loaded later.
+type webpackManifest struct {
+	Format string   `json:"format"`
+	Eager  []string `json:"eager"`
+	Lazy   []string `json:"lazy"`
+}
+
+// Find returns the path to a webpack manifest in webroot, if there is one. Only the top-level
+// keys of each candidate are read, since stats.json can be large.
+func Find(webroot fs.FS) (string, bool) {
+	for _, p := range ManifestPaths {
+		if _, err := sniffFormat(webroot, p); err == nil {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Loads a webpack webroot, described by its stats.json or webpack-assets-manifest output, into a
+// site manifest. If manifestPath is empty, ManifestPaths are tried in order.
+func Load(fsys fs.FS, manifestPath string, opts shared.Options, lg logging.Logger) (*manifest.Site, error) {
+	entry := log.Entry{WebRoot: log.WebRoot(fsys)}
+	defer func() { lg.Info(logging.NewMsgData("loaded webpack manifest", entry)) }()
+
+	var err error
+	entry.ManifestDetails, err = parseManifest(fsys, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	m := entry.ManifestDetails.Manifest.(webpackManifest)
+
+	result := manifest.Site{Index: "/index.html"}
+
+	result.Assets, err = loadAssets(fsys, m, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	shared.Finish(&result, &entry)
+
+	return &result, nil
+}
+
+func loadAssets(fsys fs.FS, m webpackManifest, opts shared.Options) (manifest.EncodedAssets, error) {
+	// First, load the initial chunks of the entrypoints
+	var result manifest.EncodedAssets
+	for _, file := range m.Eager {
+		url := shared.Url(file)
+		if result.Contains(url) {
+			continue
+		}
+
+		asset, err := shared.EncodedAsset(fsys, url, false, "webpack", opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build encoded asset from manifest %s: %w", url, err)
+		}
+
+		result = append(result, asset)
+	}
+
+	// Next, load the async chunks and other emitted assets
+	for _, file := range m.Lazy {
+		url := shared.Url(file)
+		if result.Contains(url) {
+			continue
+		}
+
+		asset, err := shared.EncodedAsset(fsys, url, true, "webpack", opts)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && path.Ext(url) == ".map":
+			continue // a missing source map only affects debugging, so it does not fail the load
+		case err != nil:
+			return nil, fmt.Errorf("failed to build encoded asset from manifest %s: %w", url, err)
+		}
+
+		result = append(result, asset)
+	}
+
+	// Finally, load files not listed in the manifest
+	err := shared.WalkFiles(fsys, func(url string) error {
+		if result.Contains(url) {
+			return nil
+		}
+
+		asset, err := shared.EncodedAsset(fsys, url, true, "filesystem", opts)
+		if err != nil {
+			return fmt.Errorf("failed to build encoded asset from file %s: %w", shared.FileName(url), err)
+		}
+
+		result = append(result, asset)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(result)
+	return result, nil
+}
+
+func parseManifest(fsys fs.FS, manifestPath string) (result log.ManifestDetails, err error) {
+	paths := ManifestPaths
+	if manifestPath != "" {
+		paths = []string{manifestPath}
+	}
+
+	for _, p := range paths {
+		var m webpackManifest
+		m, err = readManifest(fsys, p)
+		if err == nil {
+			result.Path = p
+			result.Manifest = m
+			return
+		}
+	}
+
+	err = fmt.Errorf("failed to find a webpack manifest (one of: %v): %w", paths, err)
+	return
+}
+
+// readManifest reads a stats.json or webpack-assets-manifest output, determining the
+// format from its contents.
+func readManifest(fsys fs.FS, name string) (webpackManifest, error) {
+	format, err := sniffFormat(fsys, name)
+	if err != nil {
+		return webpackManifest{}, err
+	}
+
+	data, err := fs.ReadFile(fsys, shared.FileName(name))
+	if err != nil {
+		return webpackManifest{}, err
+	}
+
+	if format == FormatStats {
+		return parseStats(data)
+	}
+
+	var raw map[string]json.RawMessage
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return webpackManifest{}, fmt.Errorf("failed to parse webpack manifest %s: %w", name, err)
+	}
+
+	return parseAssetsManifest(raw)
+}
+
+// statsKeys are top-level keys of stats.json. Stats always describe the compilation, whereas
+// the keys of an assets manifest are file names.
+var statsKeys = map[string]bool{"chunks": true, "namedChunkGroups": true, "outputPath": true, "version": true}
+
+// sniffFormat determines the format of the manifest at name from its top-level keys, without
+// parsing their values.
+func sniffFormat(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(shared.FileName(name))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return "", fmt.Errorf("failed to parse webpack manifest %s: expected a JSON object", name)
+	}
+
+	var entrypoints bool
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to parse webpack manifest %s: %w", name, err)
+		}
+
+		key, _ := tok.(string)
+		switch {
+		case statsKeys[key]:
+			return FormatStats, nil
+		case key == "entrypoints":
+			entrypoints = true
+		}
+
+		err = skipValue(dec)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse webpack manifest %s: %w", name, err)
+		}
+	}
+
+	if !entrypoints {
+		return "", fmt.Errorf("%s is not a webpack manifest: it has no entrypoints", name)
+	}
+	return FormatAssetsManifest, nil
+}
+
+// skipValue reads the next JSON value from dec, discarding it.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// stats is the part of stats.json that describes which files are loaded by each entrypoint.
+// See: https://webpack.js.org/api/stats/
+type stats struct {
+	Entrypoints map[string]statsChunkGroup `json:"entrypoints"`
+	Chunks      []statsChunk               `json:"chunks"`
+	Assets      []statsAsset               `json:"assets"`
+}
+
+type statsChunkGroup struct {
+	Assets []statsAssetRef `json:"assets"`
+}
+
+type statsChunk struct {
+	Initial        bool     `json:"initial"`
+	Files          []string `json:"files"`
+	AuxiliaryFiles []string `json:"auxiliaryFiles"`
+}
+
+type statsAsset struct {
+	Name string `json:"name"`
+}
+
+// statsAssetRef is the name of an asset in a chunk group. Webpack 4 lists names, and webpack 5 lists objects.
+type statsAssetRef string
+
+// UnmarshalJSON implements json.Unmarshaler.UnmarshalJSON()
+func (r *statsAssetRef) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*r = statsAssetRef(name)
+		return nil
+	}
+
+	var asset statsAsset
+	if err := json.Unmarshal(data, &asset); err != nil {
+		return err
+	}
+	*r = statsAssetRef(asset.Name)
+	return nil
+}
+
+func parseStats(data []byte) (webpackManifest, error) {
+	var s stats
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return webpackManifest{}, fmt.Errorf("failed to parse webpack stats: %w", err)
+	}
+
+	result := webpackManifest{Format: FormatStats}
+
+	for _, name := range sortedKeys(s.Entrypoints) {
+		for _, a := range s.Entrypoints[name].Assets {
+			result.add(string(a), true)
+		}
+	}
+
+	for _, c := range s.Chunks {
+		for _, f := range c.Files {
+			result.add(f, c.Initial)
+		}
+		for _, f := range c.AuxiliaryFiles {
+			result.add(f, false)
+		}
+	}
+
+	for _, a := range s.Assets {
+		result.add(a.Name, false)
+	}
+
+	return result.dedupe(), nil
+}
+
+// assetsManifestEntry is a value in an assets manifest. It is a file name, or an object
+// with a file name if subresource integrity is enabled.
+type assetsManifestEntry string
+
+// UnmarshalJSON implements json.Unmarshaler.UnmarshalJSON()
+func (e *assetsManifestEntry) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*e = assetsManifestEntry(name)
+		return nil
+	}
+
+	var entry struct {
+		Src string `json:"src"`
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return err
+	}
+	*e = assetsManifestEntry(entry.Src)
+	return nil
+}
+
+// parseAssetsManifest parses webpack-assets-manifest output with entrypoints enabled. The
+// files of each entrypoint are grouped by extension, optionally under an "assets" key.
+// See: https://github.com/webdeveric/webpack-assets-manifest
+func parseAssetsManifest(raw map[string]json.RawMessage) (webpackManifest, error) {
+	result := webpackManifest{Format: FormatAssetsManifest}
+
+	var entrypoints map[string]map[string]json.RawMessage
+	err := json.Unmarshal(raw["entrypoints"], &entrypoints)
+	if err != nil {
+		return webpackManifest{}, fmt.Errorf("failed to parse webpack assets manifest entrypoints: %w", err)
+	}
+
+	for _, name := range sortedKeys(entrypoints) {
+		groups := entrypoints[name]
+		if assets, ok := groups["assets"]; ok {
+			groups = nil
+			err = json.Unmarshal(assets, &groups)
+			if err != nil {
+				return webpackManifest{}, fmt.Errorf("failed to parse webpack assets manifest entrypoint %s: %w", name, err)
+			}
+		}
+
+		for _, ext := range sortedKeys(groups) {
+			var files []assetsManifestEntry
+			err = json.Unmarshal(groups[ext], &files)
+			if err != nil {
+				return webpackManifest{}, fmt.Errorf("failed to parse webpack assets manifest entrypoint %s: %w", name, err)
+			}
+			for _, f := range files {
+				result.add(string(f), true)
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(raw) {
+		if key == "entrypoints" {
+			continue
+		}
+
+		var f assetsManifestEntry
+		if err := json.Unmarshal(raw[key], &f); err == nil {
+			result.add(string(f), false)
+		}
+	}
+
+	return result.dedupe(), nil
+}
+
+// add adds a file. Source maps are never eager, since browsers only load them for debugging.
+func (m *webpackManifest) add(file string, eager bool) {
+	if file == "" {
+		return
+	}
+
+	if eager && path.Ext(file) != ".map" {
+		m.Eager = append(m.Eager, file)
+	} else {
+		m.Lazy = append(m.Lazy, file)
+	}
+}
+
+// dedupe removes duplicates, and lazy files that are also eager.
+func (m webpackManifest) dedupe() webpackManifest {
+	seen := make(map[string]bool)
+	dedupe := func(files []string) []string {
+		var result []string
+		for _, f := range files {
+			if !seen[f] {
+				seen[f] = true
+				result = append(result, f)
+			}
+		}
+		return result
+	}
+
+	m.Eager = dedupe(m.Eager)
+	m.Lazy = dedupe(m.Lazy)
+	return m
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
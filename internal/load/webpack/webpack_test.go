@@ -0,0 +1,52 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package webpack
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestSniffFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{name: "stats", data: `{"version":"5.88.0","entrypoints":{"main":{"assets":["main.js"]}}}`, want: FormatStats},
+		{name: "stats key after entrypoints", data: `{"entrypoints":{"main":{"assets":["main.js"]}},"chunks":[{"files":["main.js"]}]}`, want: FormatStats},
+		{name: "assets manifest", data: `{"main.js":"main.1a2b3c.js","entrypoints":{"main":{"assets":{"js":["main.1a2b3c.js"]}}}}`, want: FormatAssetsManifest},
+		{name: "no entrypoints", data: `{"main.js":"main.1a2b3c.js"}`, wantErr: true},
+		{name: "not an object", data: `["main.js"]`, wantErr: true},
+		{name: "invalid json", data: `{"main.js":`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"stats.json": &fstest.MapFile{Data: []byte(tt.data)}}
+			got, err := sniffFormat(fsys, "stats.json")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sniffFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("sniffFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package logging provides the logger used by dayspa, and its backends.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ajjensen13/gke"
+)
+
+// Logger logs payloads at various severities. A payload is usually a string or a MsgData.
+// gke.Logger implements Logger.
+type Logger interface {
+	Debug(payload interface{})
+	Info(payload interface{})
+	Notice(payload interface{})
+	Warning(payload interface{})
+	Error(payload interface{})
+
+	// The formatted variants return the formatted string.
+	Debugf(format string, args ...interface{}) string
+	Infof(format string, args ...interface{}) string
+	Noticef(format string, args ...interface{}) string
+	Warningf(format string, args ...interface{}) string
+	Errorf(format string, args ...interface{}) string
+
+	// The error variants return err.
+	WarningErr(err error) error
+	ErrorErr(err error) error
+}
+
+// MsgData is a log payload consisting of a message and structured data.
+type MsgData struct {
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// NewMsgData returns a MsgData.
+func NewMsgData(msg string, data interface{}) MsgData {
+	return MsgData{Message: msg, Data: data}
+}
+
+// Discard is a Logger that discards every entry.
+var Discard Logger = newStreamLogger(ioutil.Discard, func(*bytes.Buffer, entry) {})
+
+// Backend is the name of a logging backend.
+type Backend string
+
+const (
+	// Json writes one JSON object per entry to stdout. The entries use the field names
+	// recognized by Cloud Logging, so they are also structured when running in GKE.
+	Json Backend = "json"
+	// Console writes human-readable entries to stderr.
+	Console Backend = "console"
+	// GKE writes entries directly to Cloud Logging. It requires the GCE metadata server.
+	GKE Backend = "gke"
+)
+
+// Backends lists the supported backends.
+var Backends = []Backend{Json, Console, GKE}
+
+// New returns a logger for the backend. The cleanup function flushes the logger.
+func New(ctx context.Context, backend Backend) (Logger, func(), error) {
+	switch backend {
+	case Json:
+		return newStreamLogger(os.Stdout, jsonFormat), func() {}, nil
+	case Console:
+		return newStreamLogger(os.Stderr, consoleFormat), func() {}, nil
+	case GKE:
+		lg, cleanup, err := gke.NewLogger(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		gke.LogEnv(lg)
+		gke.LogMetadata(lg)
+		return lg, cleanup, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported log backend: %s (supported backends: %s)", backend, BackendNames())
+	}
+}
+
+// BackendNames returns a comma separated list of the supported backends.
+func BackendNames() string {
+	names := make([]string, 0, len(Backends))
+	for _, b := range Backends {
+		names = append(names, string(b))
+	}
+	return strings.Join(names, ", ")
+}
+
+// StandardLogger returns a *log.Logger that logs each line at Error severity,
+// e.g. for use as http.Server.ErrorLog.
+func StandardLogger(lg Logger) *log.Logger {
+	return log.New(errorWriter{lg}, "", 0)
+}
+
+type errorWriter struct {
+	lg Logger
+}
+
+func (w errorWriter) Write(p []byte) (int, error) {
+	w.lg.Error(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+var _ Logger = gke.Logger{}
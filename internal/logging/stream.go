@@ -0,0 +1,165 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type severity string
+
+const (
+	severityDebug   severity = "DEBUG"
+	severityInfo    severity = "INFO"
+	severityNotice  severity = "NOTICE"
+	severityWarning severity = "WARNING"
+	severityError   severity = "ERROR"
+)
+
+// entry is a single log entry. Its JSON field names follow the Cloud Logging
+// structured logging conventions.
+// See: https://cloud.google.com/logging/docs/structured-logging
+type entry struct {
+	Severity severity    `json:"severity"`
+	Time     time.Time   `json:"time"`
+	Message  string      `json:"message,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+func newEntry(s severity, payload interface{}) entry {
+	result := entry{Severity: s, Time: time.Now()}
+	switch p := payload.(type) {
+	case string:
+		result.Message = p
+	case MsgData:
+		result.Message = p.Message
+		result.Data = p.Data
+	case error:
+		result.Message = p.Error()
+	default:
+		result.Data = p
+	}
+	return result
+}
+
+type format func(w *bytes.Buffer, e entry)
+
+func jsonFormat(w *bytes.Buffer, e entry) {
+	err := json.NewEncoder(w).Encode(e)
+	if err != nil {
+		w.Reset()
+		_ = json.NewEncoder(w).Encode(entry{Severity: e.Severity, Time: e.Time, Message: e.Message, Data: fmt.Sprintf("%+v", e.Data)})
+	}
+}
+
+func consoleFormat(w *bytes.Buffer, e entry) {
+	_, _ = fmt.Fprintf(w, "%s %-7s %s", e.Time.Format("2006-01-02T15:04:05.000Z07:00"), e.Severity, e.Message)
+	if e.Data != nil {
+		data, err := json.Marshal(e.Data)
+		if err != nil {
+			data = []byte(fmt.Sprintf("%+v", e.Data))
+		}
+		w.WriteByte(' ')
+		w.Write(data)
+	}
+	w.WriteByte('\n')
+}
+
+// streamLogger writes formatted entries to a stream.
+type streamLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format format
+}
+
+func newStreamLogger(w io.Writer, f format) *streamLogger {
+	return &streamLogger{w: w, format: f}
+}
+
+func (l *streamLogger) log(s severity, payload interface{}) {
+	var buf bytes.Buffer
+	l.format(&buf, newEntry(s, payload))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(buf.Bytes())
+}
+
+func (l *streamLogger) logf(s severity, format string, args ...interface{}) string {
+	result := fmt.Sprintf(format, args...)
+	l.log(s, result)
+	return result
+}
+
+func (l *streamLogger) logErr(s severity, err error) error {
+	if err != nil {
+		l.log(s, err.Error())
+	}
+	return err
+}
+
+// Debug implements Logger.Debug()
+func (l *streamLogger) Debug(payload interface{}) { l.log(severityDebug, payload) }
+
+// Info implements Logger.Info()
+func (l *streamLogger) Info(payload interface{}) { l.log(severityInfo, payload) }
+
+// Notice implements Logger.Notice()
+func (l *streamLogger) Notice(payload interface{}) { l.log(severityNotice, payload) }
+
+// Warning implements Logger.Warning()
+func (l *streamLogger) Warning(payload interface{}) { l.log(severityWarning, payload) }
+
+// Error implements Logger.Error()
+func (l *streamLogger) Error(payload interface{}) { l.log(severityError, payload) }
+
+// Debugf implements Logger.Debugf()
+func (l *streamLogger) Debugf(format string, args ...interface{}) string {
+	return l.logf(severityDebug, format, args...)
+}
+
+// Infof implements Logger.Infof()
+func (l *streamLogger) Infof(format string, args ...interface{}) string {
+	return l.logf(severityInfo, format, args...)
+}
+
+// Noticef implements Logger.Noticef()
+func (l *streamLogger) Noticef(format string, args ...interface{}) string {
+	return l.logf(severityNotice, format, args...)
+}
+
+// Warningf implements Logger.Warningf()
+func (l *streamLogger) Warningf(format string, args ...interface{}) string {
+	return l.logf(severityWarning, format, args...)
+}
+
+// Errorf implements Logger.Errorf()
+func (l *streamLogger) Errorf(format string, args ...interface{}) string {
+	return l.logf(severityError, format, args...)
+}
+
+// WarningErr implements Logger.WarningErr()
+func (l *streamLogger) WarningErr(err error) error { return l.logErr(severityWarning, err) }
+
+// ErrorErr implements Logger.ErrorErr()
+func (l *streamLogger) ErrorErr(err error) error { return l.logErr(severityError, err) }
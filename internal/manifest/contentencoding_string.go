@@ -11,11 +11,13 @@ func _() {
 	_ = x[Identity-0]
 	_ = x[Gzip-1]
 	_ = x[Deflate-2]
+	_ = x[Brotli-3]
+	_ = x[Zstd-4]
 }
 
-const _ContentEncoding_name = "identitygzipdeflate"
+const _ContentEncoding_name = "identitygzipdeflatebrzstd"
 
-var _ContentEncoding_index = [...]uint8{0, 8, 12, 19}
+var _ContentEncoding_index = [...]uint8{0, 8, 12, 19, 21, 25}
 
 func (i ContentEncoding) String() string {
 	if i < 0 || i >= ContentEncoding(len(_ContentEncoding_index)-1) {
@@ -18,6 +18,7 @@
 package manifest
 
 import (
+	"fmt"
 	"strings"
 	"time"
 )
@@ -32,8 +33,25 @@ const (
 	Gzip // gzip
 	// Deflate Content-ContentEncoding
 	Deflate // deflate
+	// Brotli Content-ContentEncoding
+	Brotli // br
+	// Zstd Content-ContentEncoding
+	Zstd // zstd
 )
 
+// ContentEncodings lists every supported ContentEncoding.
+var ContentEncodings = []ContentEncoding{Identity, Gzip, Deflate, Brotli, Zstd}
+
+// ParseContentEncoding returns the ContentEncoding with the given name (e.g. "gzip").
+func ParseContentEncoding(s string) (ContentEncoding, error) {
+	for _, e := range ContentEncodings {
+		if strings.EqualFold(s, e.String()) {
+			return e, nil
+		}
+	}
+	return Identity, fmt.Errorf("unsupported content encoding: %s", s)
+}
+
 // ContentType is used to prioritize asset types based on the Critical Rendering Path.
 // See: https://developers.google.com/web/fundamentals/performance/critical-rendering-path
 type ContentType string
@@ -64,11 +82,15 @@ type EncodedAsset struct {
 	Etag        string      `json:"etag"`
 	Data        EncodedData `json:"-"`
 	Source      string      `json:"source"`
+	// Group is the name of the asset group that the asset belongs to, if the source
+	// manifest groups assets (e.g. ngsw.json assetGroups).
+	Group string `json:"group,omitempty"`
 }
 
 // EncodedDatum represents a single encoding of a single asset.
 type EncodedDatum struct {
 	ContentEncoding ContentEncoding `json:"content_encoding"`
+	Etag            string          `json:"etag"`
 	Data            []byte          `json:"-"`
 }
 
@@ -94,9 +116,18 @@ func (e EncodedData) Swap(i, j int) {
 
 // Site represents a loaded site.
 type Site struct {
-	Index    string        `json:"index"`
-	Checksum string        `json:"checksum"`
-	Assets   EncodedAssets `json:"assets"`
+	Index          string          `json:"index"`
+	Checksum       string          `json:"checksum"`
+	Assets         EncodedAssets   `json:"assets"`
+	NavigationUrls []NavigationUrl `json:"navigation_urls,omitempty"`
+}
+
+// NavigationUrl is a pattern used to decide whether a request is a navigation request.
+// A request is a navigation request if its path matches at least one positive pattern
+// and no negative patterns.
+type NavigationUrl struct {
+	Positive bool   `json:"positive"`
+	Regex    string `json:"regex"`
 }
 
 // EncodedAssets is a sorted list of EncodedAssets.
@@ -0,0 +1,158 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+// validators are the validators of the selected representation of an asset.
+type validators struct {
+	Etag         string
+	LastModified time.Time
+}
+
+// representationValidators returns the validators of a single encoding of an asset.
+func representationValidators(asset *manifest.EncodedAsset, datum *manifest.EncodedDatum) validators {
+	result := validators{Etag: datum.Etag, LastModified: lastModified(asset.ModTime)}
+	if result.Etag == "" {
+		result.Etag = asset.Etag
+	}
+	return result
+}
+
+// lastModified returns the modification time at the resolution of an HTTP-date.
+func lastModified(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// evaluatePreconditions evaluates the conditional request headers in the order
+// defined by RFC 9110. It returns 0 if the request should proceed, or the status
+// code that should be sent instead (304 or 412).
+// See: https://www.rfc-editor.org/rfc/rfc9110#name-precedence-of-preconditions
+func evaluatePreconditions(r *http.Request, v validators) int {
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !etagListMatches(im, v.Etag, strongComparison) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); ius != "" {
+		if t, err := http.ParseTime(ius); err == nil && !v.LastModified.IsZero() && v.LastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagListMatches(inm, v.Etag, weakComparison) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && safe {
+		if t, err := http.ParseTime(ims); err == nil && !v.LastModified.IsZero() && !v.LastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// ifRangeMatches returns true if the If-Range precondition holds, in which case
+// the Range header is evaluated. Otherwise, the full representation is sent.
+func ifRangeMatches(r *http.Request, v validators) bool {
+	ifRange := strings.TrimSpace(r.Header.Get("If-Range"))
+	switch {
+	case ifRange == "":
+		return true
+	case strings.HasPrefix(ifRange, `W/`) || strings.HasPrefix(ifRange, `"`):
+		return etagMatches(ifRange, v.Etag, strongComparison)
+	default:
+		t, err := http.ParseTime(ifRange)
+		return err == nil && !v.LastModified.IsZero() && v.LastModified.Equal(t)
+	}
+}
+
+type etagComparison bool
+
+const (
+	strongComparison etagComparison = true
+	weakComparison   etagComparison = false
+)
+
+// etagListMatches evaluates an If-Match or If-None-Match header, which is either
+// "*" or a comma separated list of entity tags.
+func etagListMatches(list string, etag string, cmp etagComparison) bool {
+	if etag == "" {
+		return false
+	}
+
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+
+	for _, candidate := range splitETags(list) {
+		if etagMatches(candidate, etag, cmp) {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatches compares two entity tags.
+// See: https://www.rfc-editor.org/rfc/rfc9110#name-comparison
+func etagMatches(a, b string, cmp etagComparison) bool {
+	aOpaque, aWeak := parseETag(a)
+	bOpaque, bWeak := parseETag(b)
+	if cmp == strongComparison && (aWeak || bWeak) {
+		return false
+	}
+	return aOpaque != "" && aOpaque == bOpaque
+}
+
+// parseETag splits an entity tag into its opaque tag and weakness indicator.
+func parseETag(etag string) (opaque string, weak bool) {
+	etag = strings.TrimSpace(etag)
+	if strings.HasPrefix(etag, "W/") {
+		weak = true
+		etag = etag[2:]
+	}
+	return strings.Trim(etag, `"`), weak
+}
+
+// splitETags splits a comma separated list of entity tags. Commas inside of
+// quoted opaque tags do not split the list.
+func splitETags(list string) (result []string) {
+	start, quoted := 0, false
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				result = append(result, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(result, list[start:])
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+// acceptEncoding is a parsed Accept-Encoding header.
+// See: https://www.rfc-editor.org/rfc/rfc9110#name-accept-encoding
+type acceptEncoding struct {
+	present  bool
+	codings  map[string]float64
+	wildcard *float64
+}
+
+func parseAcceptEncoding(r *http.Request) (result acceptEncoding) {
+	values, ok := r.Header["Accept-Encoding"]
+	if !ok {
+		return
+	}
+
+	result.present = true
+	result.codings = make(map[string]float64)
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			params := strings.Split(element, ";")
+
+			coding := strings.ToLower(strings.TrimSpace(params[0]))
+			if coding == "" {
+				continue
+			}
+
+			q, ok := parseQValue(params[1:])
+			if !ok {
+				continue
+			}
+
+			switch coding {
+			case "*":
+				result.wildcard = &q
+			case "x-gzip":
+				result.codings["gzip"] = q
+			default:
+				result.codings[coding] = q
+			}
+		}
+	}
+	return
+}
+
+// parseQValue returns the weight of an Accept-Encoding element. Elements with an
+// invalid weight are ignored.
+func parseQValue(params []string) (float64, bool) {
+	for _, param := range params {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 || !strings.EqualFold(strings.TrimSpace(kv[0]), "q") {
+			continue
+		}
+
+		q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0, false
+		}
+		return q, true
+	}
+	return 1, true
+}
+
+// acceptable returns true if the client accepts the content encoding.
+func (a acceptEncoding) acceptable(ce manifest.ContentEncoding) bool {
+	if ce == manifest.Identity {
+		return a.acceptableIdentity()
+	}
+
+	if !a.present { // only identity is sent to clients that do not state a preference
+		return false
+	}
+
+	if q, ok := a.codings[ce.String()]; ok {
+		return q > 0
+	}
+
+	return a.wildcard != nil && *a.wildcard > 0
+}
+
+// acceptableIdentity returns true unless identity is explicitly excluded,
+// either with "identity;q=0" or with "*;q=0" when identity is not listed.
+func (a acceptEncoding) acceptableIdentity() bool {
+	if q, ok := a.codings[manifest.Identity.String()]; ok {
+		return q > 0
+	}
+	return a.wildcard == nil || *a.wildcard > 0
+}
+
+// encodingSet is a set of content encodings. A nil set contains every encoding.
+type encodingSet map[manifest.ContentEncoding]bool
+
+func newEncodingSet(encodings []manifest.ContentEncoding) encodingSet {
+	if len(encodings) == 0 {
+		return nil
+	}
+
+	result := encodingSet{manifest.Identity: true} // identity is always available
+	for _, e := range encodings {
+		result[e] = true
+	}
+	return result
+}
+
+func (s encodingSet) contains(ce manifest.ContentEncoding) bool {
+	return s == nil || s[ce]
+}
+
+// negotiateEncoding returns the smallest encoding of an asset that is both enabled, and
+// acceptable to the client.
+func negotiateEncoding(r *http.Request, data manifest.EncodedData, enabled encodingSet) (*manifest.EncodedDatum, bool) {
+	ae := parseAcceptEncoding(r)
+	for _, datum := range data { // data is sorted from smallest to largest
+		if enabled.contains(datum.ContentEncoding) && ae.acceptable(datum.ContentEncoding) {
+			return datum, true
+		}
+	}
+	return nil, false
+}
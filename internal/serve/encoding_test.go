@@ -0,0 +1,108 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+func TestAcceptEncodingAcceptable(t *testing.T) {
+	all := []manifest.ContentEncoding{manifest.Identity, manifest.Gzip, manifest.Deflate, manifest.Brotli, manifest.Zstd}
+
+	tests := []struct {
+		name   string
+		header []string // nil means the header is absent
+		want   []manifest.ContentEncoding
+	}{
+		{name: "absent", header: nil, want: []manifest.ContentEncoding{manifest.Identity}},
+		{name: "empty", header: []string{""}, want: []manifest.ContentEncoding{manifest.Identity}},
+		{name: "list", header: []string{"gzip, br"}, want: []manifest.ContentEncoding{manifest.Identity, manifest.Gzip, manifest.Brotli}},
+		{name: "multiple headers", header: []string{"gzip", "zstd"}, want: []manifest.ContentEncoding{manifest.Identity, manifest.Gzip, manifest.Zstd}},
+		{name: "case insensitive", header: []string{"GZip, BR"}, want: []manifest.ContentEncoding{manifest.Identity, manifest.Gzip, manifest.Brotli}},
+		{name: "x-gzip", header: []string{"x-gzip"}, want: []manifest.ContentEncoding{manifest.Identity, manifest.Gzip}},
+		{name: "zero weight", header: []string{"gzip;q=0, br;q=0.5"}, want: []manifest.ContentEncoding{manifest.Identity, manifest.Brotli}},
+		{name: "invalid weight", header: []string{"gzip;q=2, br;q=x, deflate"}, want: []manifest.ContentEncoding{manifest.Identity, manifest.Deflate}},
+		{name: "wildcard", header: []string{"*"}, want: all},
+		{name: "wildcard with exclusion", header: []string{"*, br;q=0"}, want: []manifest.ContentEncoding{manifest.Identity, manifest.Gzip, manifest.Deflate, manifest.Zstd}},
+		{name: "identity excluded", header: []string{"gzip, identity;q=0"}, want: []manifest.ContentEncoding{manifest.Gzip}},
+		{name: "wildcard excluded", header: []string{"*;q=0"}, want: nil},
+		{name: "wildcard excluded with identity", header: []string{"*;q=0, identity"}, want: []manifest.ContentEncoding{manifest.Identity}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != nil {
+				r.Header["Accept-Encoding"] = tt.header
+			}
+
+			want := make(map[manifest.ContentEncoding]bool)
+			for _, ce := range tt.want {
+				want[ce] = true
+			}
+
+			ae := parseAcceptEncoding(r)
+			for _, ce := range all {
+				if got := ae.acceptable(ce); got != want[ce] {
+					t.Errorf("acceptable(%s) with Accept-Encoding %q = %v, want %v", ce, tt.header, got, want[ce])
+				}
+			}
+		})
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	data := manifest.EncodedData{ // sorted from smallest to largest
+		{ContentEncoding: manifest.Brotli, Data: make([]byte, 10)},
+		{ContentEncoding: manifest.Gzip, Data: make([]byte, 20)},
+		{ContentEncoding: manifest.Identity, Data: make([]byte, 100)},
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		enabled []manifest.ContentEncoding
+		want    manifest.ContentEncoding
+		wantOk  bool
+	}{
+		{name: "smallest acceptable", header: "gzip, br", want: manifest.Brotli, wantOk: true},
+		{name: "only gzip", header: "gzip", want: manifest.Gzip, wantOk: true},
+		{name: "brotli disabled", header: "gzip, br", enabled: []manifest.ContentEncoding{manifest.Gzip}, want: manifest.Gzip, wantOk: true},
+		{name: "nothing compressed acceptable", header: "zstd", want: manifest.Identity, wantOk: true},
+		{name: "nothing acceptable", header: "zstd, identity;q=0", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.header)
+
+			got, ok := negotiateEncoding(r, data, newEncodingSet(tt.enabled))
+			if ok != tt.wantOk {
+				t.Fatalf("negotiateEncoding(%q) ok = %v, want %v", tt.header, ok, tt.wantOk)
+			}
+			if ok && got.ContentEncoding != tt.want {
+				t.Errorf("negotiateEncoding(%q) = %s, want %s", tt.header, got.ContentEncoding, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ajjensen13/dayspa/internal/glob"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+type fallback struct {
+	Enabled    bool
+	Exclude    []*glob.Pattern
+	Strict     bool
+	Navigation *navigationUrls
+}
+
+// navigationUrls mirrors the navigation request matching done by the
+// Angular service worker for the navigationUrls in ngsw.json.
+type navigationUrls struct {
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+}
+
+func compileNavigationUrls(urls []manifest.NavigationUrl) (*navigationUrls, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	var result navigationUrls
+	for _, u := range urls {
+		re, err := regexp.Compile(u.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile navigation url pattern %q: %w", u.Regex, err)
+		}
+
+		if u.Positive {
+			result.Include = append(result.Include, re)
+		} else {
+			result.Exclude = append(result.Exclude, re)
+		}
+	}
+	return &result, nil
+}
+
+func (n *navigationUrls) match(p string) bool {
+	return matchAny(n.Include, p) && !matchAny(n.Exclude, p)
+}
+
+func matchAny(res []*regexp.Regexp, p string) bool {
+	for _, re := range res {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup finds the asset for the request. If the path is unknown and the
+// request is a navigation request, the site's index is returned instead.
+func (h *handler) lookup(r *http.Request, details *serveDetails) (*manifest.EncodedAsset, bool) {
+	if asset, ok := h.LookupPath[r.URL.Path]; ok {
+		return asset, true
+	}
+
+	if !h.Fallback.accepts(r) {
+		return nil, false
+	}
+
+	asset, ok := h.LookupPath[h.Index]
+	if !ok {
+		return nil, false
+	}
+
+	details.Fallback = true
+	return asset, true
+}
+
+// accepts returns true if the request should be answered with the site's index.
+func (f *fallback) accepts(r *http.Request) bool {
+	p := r.URL.Path
+	switch {
+	case !f.Enabled:
+		return false
+	case glob.MatchAny(f.Exclude, p):
+		return false
+	case f.Strict && !navigationUrl(p): // the path looks like a file
+		return false
+	case f.Navigation != nil: // the site defines its own navigation urls
+		return acceptsHtml(r) && f.Navigation.match(p)
+	case navigationUrl(p):
+		return true
+	default:
+		return acceptsHtml(r)
+	}
+}
+
+func acceptsHtml(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, v := range strings.Split(accept, ",") {
+			mt := strings.TrimSpace(strings.SplitN(v, ";", 2)[0])
+			if strings.EqualFold(mt, "text/html") {
+				return true
+			}
+		}
+	}
+	return false
+}
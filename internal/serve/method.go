@@ -0,0 +1,72 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// allowedMethods is the value of the Allow header.
+const allowedMethods = "GET, HEAD, OPTIONS"
+
+// methodDecision records how the handler decided to respond to a request method.
+type methodDecision string
+
+const (
+	methodServe      methodDecision = "serve"
+	methodServeHead  methodDecision = "serve_head"
+	methodOptions    methodDecision = "options"
+	methodNotAllowed methodDecision = "not_allowed"
+)
+
+func serveOptions(wr http.ResponseWriter) (result serveDetails) {
+	result.Method = methodOptions
+	result.Status = http.StatusNoContent
+
+	wr.Header().Set("Allow", allowedMethods)
+	wr.WriteHeader(http.StatusNoContent)
+	return
+}
+
+func serveMethodNotAllowed(wr http.ResponseWriter) (result serveDetails) {
+	result.Method = methodNotAllowed
+	result.Status = http.StatusMethodNotAllowed
+
+	wr.Header().Set("Allow", allowedMethods)
+	http.Error(wr, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return
+}
+
+// writeData writes a response with a Content-Length. The body is omitted for HEAD requests.
+// The number of body bytes written is returned.
+func writeData(wr http.ResponseWriter, r *http.Request, status int, data []byte) int {
+	wr.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	wr.WriteHeader(status)
+
+	if r.Method == http.MethodHead {
+		return 0
+	}
+
+	_, err := wr.Write(data)
+	if err != nil {
+		panic(err)
+	}
+
+	return len(data)
+}
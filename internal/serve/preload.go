@@ -0,0 +1,172 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+type preloadDetails struct {
+	RequestTriggersPreload   *bool    `json:"request_triggers_preload"`
+	ClientNeedsAssets        *bool    `json:"client_needs_assets"`
+	ClientSupportsEarlyHints *bool    `json:"client_supports_early_hints"`
+	EarlyHintsSent           bool     `json:"early_hints_sent"`
+	ServerChecksum           string   `json:"server_checksum,omitempty"`
+	ClientChecksum           string   `json:"client_checksum,omitempty"`
+	Assets                   []string `json:"assets"`
+}
+
+// pushCookieName is the cookie that records the site checksum the client has already
+// been hinted. It predates preload hints, when assets were sent with HTTP/2 server push.
+const pushCookieName = "_dayspa_push"
+
+// preloadLink is a Link header that hints an eagerly loaded asset.
+// See: https://www.w3.org/TR/preload/
+type preloadLink struct {
+	Url   string
+	Value string
+}
+
+// preloadLinks returns the Link headers for the eager assets of a site, in the
+// order of their ContentType.Priority().
+func preloadLinks(site *manifest.Site) []preloadLink {
+	result := make([]preloadLink, 0, len(site.Assets))
+	for _, asset := range site.Assets { // assets are sorted by priority
+		if asset.Lazy {
+			continue
+		}
+
+		if requestTriggersPreload(asset.Url, site.Index) {
+			continue
+		}
+
+		as, ok := preloadDestination(asset.ContentType)
+		if !ok {
+			continue
+		}
+
+		value := fmt.Sprintf("<%s>; rel=preload; as=%s", asset.Url, as)
+		if as == "font" {
+			value += "; crossorigin" // fonts are always fetched in cors mode
+		}
+
+		result = append(result, preloadLink{Url: asset.Url, Value: value})
+	}
+	return result
+}
+
+// preloadDestination returns the request destination of an asset, which is used as
+// the "as" attribute of the preload link.
+func preloadDestination(c manifest.ContentType) (string, bool) {
+	s := string(c)
+	switch {
+	case strings.HasPrefix(s, "text/javascript"):
+		return "script", true
+	case strings.HasPrefix(s, "text/css"):
+		return "style", true
+	case strings.HasPrefix(s, "font/"), strings.HasPrefix(s, "application/font-"), strings.HasPrefix(s, "application/x-font-"):
+		return "font", true
+	default:
+		return "", false
+	}
+}
+
+func requestTriggersPreload(p string, index string) bool {
+	return p == index || navigationUrl(p)
+}
+
+// clientSupportsEarlyHints returns true if the client can be sent a 103 Early Hints
+// response. Some HTTP/1.1 clients do not handle informational responses, so they
+// only receive the Link headers on the final response.
+//
+// The dayspa command only serves HTTP/1.1 (it listens without TLS or h2c), so it never
+// sends 103 responses itself. They are sent when the handler is served over HTTP/2,
+// e.g. when it is embedded with the dayspa package in a server that uses TLS.
+// Proxies that terminate HTTP/2 in front of the command can still turn the Link
+// headers of the final response into early hints.
+func clientSupportsEarlyHints(r *http.Request) bool {
+	return r.ProtoAtLeast(2, 0)
+}
+
+// tryPreload adds preload hints for the site's eager assets when asset is the
+// site's index. It must only be called once the response is known to succeed.
+func (h *handler) tryPreload(wr http.ResponseWriter, r *http.Request, asset *manifest.EncodedAsset) (result preloadDetails) {
+	result.ServerChecksum = h.Checksum
+	if asset.Url != h.Index {
+		result.RequestTriggersPreload = &boolFalse
+		return
+	}
+	result.RequestTriggersPreload = &boolTrue
+
+	if clientHasAssets(r, h.Checksum, &result) {
+		result.ClientNeedsAssets = &boolFalse
+		return
+	}
+	result.ClientNeedsAssets = &boolTrue
+
+	header := wr.Header()
+	result.Assets = make([]string, 0, len(h.Preload))
+	for _, link := range h.Preload {
+		result.Assets = append(result.Assets, link.Url)
+		header.Add("Link", link.Value)
+	}
+
+	switch {
+	case !clientSupportsEarlyHints(r):
+		result.ClientSupportsEarlyHints = &boolFalse
+	case len(h.Preload) == 0:
+		result.ClientSupportsEarlyHints = &boolTrue
+	default:
+		result.ClientSupportsEarlyHints = &boolTrue
+		// net/http only sends 1xx statuses as informational responses since Go 1.19 (see go.mod).
+		wr.WriteHeader(http.StatusEarlyHints)
+		result.EarlyHintsSent = true
+	}
+
+	// The cookie is set after the early hints, so that it is only sent with the final response.
+	http.SetCookie(wr, &http.Cookie{
+		Name:     pushCookieName,
+		Value:    h.Checksum,
+		Path:     "/",
+		Domain:   r.Host,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(time.Hour * 24 * 365 / time.Second), // 1 year in seconds
+	})
+
+	return
+}
+
+func clientHasAssets(r *http.Request, checksum string, p *preloadDetails) bool {
+	c, err := r.Cookie(pushCookieName)
+	switch {
+	case errors.Is(err, http.ErrNoCookie):
+		return false
+	case c.Value == checksum:
+		p.ClientChecksum = c.Value
+		return true
+	default:
+		p.ClientChecksum = c.Value
+		return false
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+func TestPreload(t *testing.T) {
+	asset := func(url string, contentType manifest.ContentType) *manifest.EncodedAsset {
+		return &manifest.EncodedAsset{
+			Url:         url,
+			ContentType: contentType,
+			Etag:        `"` + url + `"`,
+			Data:        manifest.EncodedData{{ContentEncoding: manifest.Identity, Data: []byte(url)}},
+		}
+	}
+	site := &manifest.Site{
+		Index:    "/index.html",
+		Checksum: "checksum",
+		Assets: manifest.EncodedAssets{
+			asset("/index.html", "text/html; charset=utf-8"),
+			asset("/main.js", "text/javascript; charset=utf-8"),
+		},
+	}
+
+	cfg := DefaultConfig()
+	cfg.Fallback.Exclude = []string{"/api/**"}
+	h, err := Handler(site, logging.Discard, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		header      http.Header
+		wantStatus  int
+		wantPreload bool
+	}{
+		{name: "index", path: "/index.html", wantStatus: http.StatusOK, wantPreload: true},
+		{name: "fallback", path: "/orders/42", header: http.Header{"Accept": {"text/html"}}, wantStatus: http.StatusOK, wantPreload: true},
+		{name: "asset", path: "/main.js", wantStatus: http.StatusOK},
+		{name: "excluded", path: "/api/users", header: http.Header{"Accept": {"text/html"}}, wantStatus: http.StatusNotFound},
+		{name: "missing file", path: "/missing.js", wantStatus: http.StatusNotFound},
+		{name: "not modified", path: "/index.html", header: http.Header{"If-None-Match": {`"/index.html"`}}, wantStatus: http.StatusNotModified},
+		{name: "precondition failed", path: "/index.html", header: http.Header{"If-Match": {`"other"`}}, wantStatus: http.StatusPreconditionFailed},
+		{name: "not acceptable", path: "/index.html", header: http.Header{"Accept-Encoding": {"identity;q=0"}}, wantStatus: http.StatusNotAcceptable},
+		{name: "client has assets", path: "/index.html", header: http.Header{"Cookie": {pushCookieName + "=checksum"}}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			wr := httptest.NewRecorder()
+			h.ServeHTTP(wr, r)
+
+			if wr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", wr.Code, tt.wantStatus)
+			}
+			gotLink := wr.Header().Get("Link") != ""
+			gotCookie := wr.Header().Get("Set-Cookie") != ""
+			if gotLink != tt.wantPreload || gotCookie != tt.wantPreload {
+				t.Errorf("Link sent = %v, cookie set = %v, want %v", gotLink, gotCookie, tt.wantPreload)
+			}
+		})
+	}
+}
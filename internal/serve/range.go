@@ -0,0 +1,198 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/ajjensen13/dayspa/internal/manifest"
+)
+
+// byteRange is a single satisfiable range of a representation.
+// See: https://www.rfc-editor.org/rfc/rfc9110#name-byte-ranges
+type byteRange struct {
+	start, length int
+}
+
+func (b byteRange) contentRange(size int) string {
+	return fmt.Sprintf("bytes %d-%d/%d", b.start, b.start+b.length-1, size)
+}
+
+var (
+	errInvalidRange       = errors.New("invalid range")
+	errUnsatisfiableRange = errors.New("unsatisfiable range")
+)
+
+// parseRange parses a Range header against a representation of the given size.
+// errInvalidRange is returned if the header should be ignored, and errUnsatisfiableRange
+// is returned if none of the ranges overlap the representation.
+func parseRange(s string, size int) ([]byteRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(s, prefix) {
+		return nil, errInvalidRange
+	}
+
+	var result []byteRange
+	for _, spec := range strings.Split(s[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		i := strings.Index(spec, "-")
+		if i < 0 {
+			return nil, errInvalidRange
+		}
+
+		first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+		var r byteRange
+		if first == "" { // suffix range, e.g. "-500"
+			n, err := strconv.Atoi(last)
+			if err != nil || n < 0 {
+				return nil, errInvalidRange
+			}
+			if n > size {
+				n = size
+			}
+			if n == 0 { // e.g. "-0", or any suffix of an empty representation
+				continue
+			}
+			r = byteRange{start: size - n, length: n}
+		} else {
+			start, err := strconv.Atoi(first)
+			if err != nil || start < 0 {
+				return nil, errInvalidRange
+			}
+			if start >= size {
+				continue
+			}
+
+			end := size - 1
+			if last != "" {
+				end, err = strconv.Atoi(last)
+				if err != nil || end < start {
+					return nil, errInvalidRange
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			r = byteRange{start: start, length: end - start + 1}
+		}
+		result = append(result, r)
+	}
+
+	if len(result) == 0 {
+		return nil, errUnsatisfiableRange
+	}
+
+	return result, nil
+}
+
+// rangeRequested returns true if the request contains a Range header that
+// should be evaluated. Range headers are only defined for GET requests.
+func rangeRequested(r *http.Request) bool {
+	return r.Method == http.MethodGet && r.Header.Get("Range") != ""
+}
+
+// serveRanges serves the requested ranges of the identity encoding of an asset.
+// cacheControl is only sent with a 206 response.
+func serveRanges(wr http.ResponseWriter, r *http.Request, asset *manifest.EncodedAsset, datum *manifest.EncodedDatum, cacheControl string, result *serveDetails) bool {
+	size := len(datum.Data)
+	ranges, err := parseRange(r.Header.Get("Range"), size)
+	switch {
+	case errors.Is(err, errInvalidRange):
+		return false
+	case errors.Is(err, errUnsatisfiableRange):
+		wr.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		result.Status = http.StatusRequestedRangeNotSatisfiable
+		http.Error(wr, http.StatusText(http.StatusRequestedRangeNotSatisfiable), http.StatusRequestedRangeNotSatisfiable)
+		return true
+	}
+
+	if rangesSize(ranges) > size { // requesting more than the whole representation is pointless, and abusive
+		return false
+	}
+
+	header := wr.Header()
+	setValidators(header, representationValidators(asset, datum))
+	setCacheControl(header, cacheControl)
+	result.ContentEncoding = datum.ContentEncoding.String()
+	result.Ranges = len(ranges)
+
+	var body []byte
+	if len(ranges) == 1 {
+		rng := ranges[0]
+		header.Set("Content-Type", string(asset.ContentType))
+		header.Set("Content-Range", rng.contentRange(size))
+		body = datum.Data[rng.start : rng.start+rng.length]
+	} else {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for _, rng := range ranges {
+			part, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":  {string(asset.ContentType)},
+				"Content-Range": {rng.contentRange(size)},
+			})
+			if err != nil {
+				panic(err)
+			}
+
+			_, err = part.Write(datum.Data[rng.start : rng.start+rng.length])
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		err := mw.Close()
+		if err != nil {
+			panic(err)
+		}
+
+		header.Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+		body = buf.Bytes()
+	}
+
+	result.Status = http.StatusPartialContent
+	result.Size = writeData(wr, r, http.StatusPartialContent, body)
+	return true
+}
+
+func rangesSize(ranges []byteRange) (result int) {
+	for _, r := range ranges {
+		result += r.length
+	}
+	return
+}
+
+// identityEncoded returns the identity encoding of an asset.
+func identityEncoded(data manifest.EncodedData) (*manifest.EncodedDatum, bool) {
+	for _, datum := range data {
+		if datum.ContentEncoding == manifest.Identity {
+			return datum, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package serve
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header  string
+		size    int
+		want    []byteRange
+		wantErr error
+	}{
+		{header: "bytes=0-499", size: 1000, want: []byteRange{{0, 500}}},
+		{header: "bytes=500-", size: 1000, want: []byteRange{{500, 500}}},
+		{header: "bytes=-200", size: 1000, want: []byteRange{{800, 200}}},
+		{header: "bytes=-2000", size: 1000, want: []byteRange{{0, 1000}}},
+		{header: "bytes=900-2000", size: 1000, want: []byteRange{{900, 100}}},
+		{header: "bytes=0-0, -1", size: 1000, want: []byteRange{{0, 1}, {999, 1}}},
+		{header: "bytes= 0-1 ,, 5-6", size: 1000, want: []byteRange{{0, 2}, {5, 2}}},
+		{header: "bytes=1000-", size: 1000, wantErr: errUnsatisfiableRange},
+		{header: "bytes=-0", size: 1000, wantErr: errUnsatisfiableRange},
+		{header: "bytes=-5", size: 0, wantErr: errUnsatisfiableRange},
+		{header: "bytes=0-", size: 0, wantErr: errUnsatisfiableRange},
+		{header: "bytes=1000-1001, 2000-", size: 1000, wantErr: errUnsatisfiableRange},
+		{header: "items=0-1", size: 1000, wantErr: errInvalidRange},
+		{header: "bytes=1", size: 1000, wantErr: errInvalidRange},
+		{header: "bytes=5-1", size: 1000, wantErr: errInvalidRange},
+		{header: "bytes=a-1", size: 1000, wantErr: errInvalidRange},
+		{header: "bytes=-a", size: 1000, wantErr: errInvalidRange},
+		{header: "bytes=-1-2", size: 1000, wantErr: errInvalidRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, err := parseRange(tt.header, tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseRange(%q, %d) error = %v, want %v", tt.header, tt.size, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRange(%q, %d) = %v, want %v", tt.header, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIfRangeMatches(t *testing.T) {
+	modTime := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	v := validators{Etag: `"abc"`, LastModified: modTime}
+
+	tests := []struct {
+		name    string
+		ifRange string
+		v       validators
+		want    bool
+	}{
+		{name: "absent", ifRange: "", v: v, want: true},
+		{name: "matching etag", ifRange: `"abc"`, v: v, want: true},
+		{name: "different etag", ifRange: `"def"`, v: v, want: false},
+		{name: "weak etag", ifRange: `W/"abc"`, v: v, want: false},
+		{name: "matching date", ifRange: modTime.Format(http.TimeFormat), v: v, want: true},
+		{name: "earlier date", ifRange: modTime.Add(-time.Hour).Format(http.TimeFormat), v: v, want: false},
+		{name: "date without last modified", ifRange: modTime.Format(http.TimeFormat), v: validators{Etag: `"abc"`}, want: false},
+		{name: "invalid date", ifRange: "yesterday", v: v, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.ifRange != "" {
+				r.Header.Set("If-Range", tt.ifRange)
+			}
+			if got := ifRangeMatches(r, tt.v); got != tt.want {
+				t.Errorf("ifRangeMatches(%q) = %v, want %v", tt.ifRange, got, tt.want)
+			}
+		})
+	}
+}
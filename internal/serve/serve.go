@@ -19,30 +19,131 @@
 package serve
 
 import (
-	"errors"
 	"fmt"
-	"github.com/ajjensen13/gke"
 	"net/http"
 	"path"
 	"path/filepath"
-	"strings"
-	"time"
+	"sync/atomic"
 
+	"github.com/ajjensen13/dayspa/internal/cache"
+	"github.com/ajjensen13/dayspa/internal/glob"
+	"github.com/ajjensen13/dayspa/internal/logging"
 	"github.com/ajjensen13/dayspa/internal/manifest"
 )
 
+// Config configures the behavior of the http.Handler returned by Handler.
+type Config struct {
+	Fallback FallbackConfig
+	Cache    cache.Policy
+	// Encodings restricts the content encodings that are served. Identity is always
+	// served. If empty, every encoding is served.
+	Encodings []manifest.ContentEncoding
+}
+
+// DefaultConfig returns the default configuration.
+func DefaultConfig() Config {
+	return Config{
+		Fallback: FallbackConfig{Enabled: true},
+		Cache:    cache.Policy{Default: "public, max-age=3600"},
+	}
+}
+
+// FallbackConfig configures how navigation requests for unknown paths are answered.
+type FallbackConfig struct {
+	// Enabled answers navigation requests for unknown paths with the site's index.
+	Enabled bool
+	// Exclude lists glob patterns (e.g. "/api/**") that are never answered with the index.
+	Exclude []string
+	// Strict keeps 404 responses for paths that look like files, even if the client accepts text/html.
+	Strict bool
+}
+
 // Handler returns an http.Handler that serves a manifest.
-func Handler(site *manifest.Site, lg gke.Logger) http.Handler {
+// The site being served can be replaced with SiteHandler.Swap.
+func Handler(site *manifest.Site, lg logging.Logger, cfg Config) (*SiteHandler, error) {
+	exclude, err := glob.CompileAll(cfg.Fallback.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile fallback exclusions: %w", err)
+	}
+
+	cfg.Cache, err = cfg.Cache.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	result := SiteHandler{Config: cfg, Logger: lg, exclude: exclude}
+
+	_, err = result.Swap(site)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SiteHandler is an http.Handler that serves a site. The site can be swapped
+// atomically while requests are being served.
+type SiteHandler struct {
+	Config  Config
+	Logger  logging.Logger
+	exclude []*glob.Pattern
+	current atomic.Value // *handler
+}
+
+// ServeHTTP implements http.Handler.ServeHTTP()
+func (s *SiteHandler) ServeHTTP(wr http.ResponseWriter, r *http.Request) {
+	s.current.Load().(*handler).ServeHTTP(wr, r)
+}
+
+// Site returns the site currently being served.
+func (s *SiteHandler) Site() *manifest.Site {
+	return s.current.Load().(*handler).Site
+}
+
+// Swap replaces the site being served, and returns the previously served site (if any).
+// Requests already in progress complete with the previous site.
+func (s *SiteHandler) Swap(site *manifest.Site) (*manifest.Site, error) {
+	h, err := s.newHandler(site)
+	if err != nil {
+		return nil, err
+	}
+
+	var old *manifest.Site
+	if prev, ok := s.current.Load().(*handler); ok {
+		old = prev.Site
+	}
+
+	s.current.Store(h)
+	return old, nil
+}
+
+func (s *SiteHandler) newHandler(site *manifest.Site) (*handler, error) {
+	navigation, err := compileNavigationUrls(site.NavigationUrls)
+	if err != nil {
+		return nil, err
+	}
+
 	result := handler{
-		Index:      site.Index,
-		Assets:     site.Assets,
-		Checksum:   site.Checksum,
-		LookupPath: make(map[string]*manifest.EncodedAsset, len(site.Assets)),
-		Logger:     lg,
+		Site:         site,
+		Index:        site.Index,
+		Assets:       site.Assets,
+		Checksum:     site.Checksum,
+		LookupPath:   make(map[string]*manifest.EncodedAsset, len(site.Assets)),
+		CacheControl: make(map[*manifest.EncodedAsset]string, len(site.Assets)),
+		Logger:       s.Logger,
+		Encodings:    newEncodingSet(s.Config.Encodings),
+		Fallback: fallback{
+			Enabled:    s.Config.Fallback.Enabled,
+			Exclude:    s.exclude,
+			Strict:     s.Config.Fallback.Strict,
+			Navigation: navigation,
+		},
 	}
 
+	result.Preload = preloadLinks(site)
 	for _, asset := range site.Assets {
 		result.LookupPath[asset.Url] = asset
+		result.CacheControl[asset] = s.Config.Cache.CacheControl(site, asset)
 	}
 
 	for url, asset := range result.LookupPath {
@@ -58,21 +159,26 @@ func Handler(site *manifest.Site, lg gke.Logger) http.Handler {
 		result.LookupPath[dir] = asset
 	}
 
-	return &result
+	return &result, nil
 }
 
 type handler struct {
-	Index      string
-	LookupPath map[string]*manifest.EncodedAsset
-	Assets     manifest.EncodedAssets
-	Checksum   string
-	Logger     gke.Logger
+	Site         *manifest.Site
+	Index        string
+	LookupPath   map[string]*manifest.EncodedAsset
+	CacheControl map[*manifest.EncodedAsset]string
+	Assets       manifest.EncodedAssets
+	Checksum     string
+	Logger       logging.Logger
+	Encodings    encodingSet
+	Fallback     fallback
+	Preload      []preloadLink
 }
 
 type logEntry struct {
 	RequestDetails requestDetails `json:"request_details"`
 	ServeDetails   serveDetails   `json:"serve_details"`
-	PushDetails    pushDetails    `json:"push_details"`
+	PreloadDetails preloadDetails `json:"preload_details"`
 }
 
 type requestDetails struct {
@@ -86,8 +192,12 @@ func (r requestDetails) String() string {
 }
 
 type serveDetails struct {
-	Status int `json:"status"`
-	Size   int `json:"size"`
+	Method          methodDecision `json:"method"`
+	Status          int            `json:"status"`
+	Size            int            `json:"size"`
+	Fallback        bool           `json:"fallback"`
+	ContentEncoding string         `json:"content_encoding,omitempty"`
+	Ranges          int            `json:"ranges,omitempty"`
 }
 
 var (
@@ -95,142 +205,109 @@ var (
 	boolFalse = false
 )
 
-type pushDetails struct {
-	RequestTriggersPush *bool    `json:"request_triggers_push"`
-	ClientSupportsPush  *bool    `json:"client_supports_push"`
-	ClientNeedsAssets   *bool    `json:"client_needs_assets"`
-	PushAttempted       bool     `json:"push_attempted"`
-	ServerChecksum      string   `json:"server_checksum,omitempty"`
-	ClientChecksum      string   `json:"client_checksum,omitempty"`
-	Assets              []string `json:"assets"`
-}
-
-const pushCookieName = "_dayspa_push"
-
 func (h *handler) ServeHTTP(wr http.ResponseWriter, r *http.Request) {
 	entry := logEntry{RequestDetails: requestDetails{
 		Method: r.Method,
 		Host:   r.Host,
 		Path:   r.URL.Path,
 	}}
-	defer func() { h.Logger.Info(gke.NewMsgData(entry.RequestDetails.String(), entry)) }()
-
-	entry.PushDetails = h.tryPush(wr, r)
-	entry.ServeDetails = h.serveAsset(wr, r)
-}
-
-func requestTriggersPush(p string, index string) bool {
-	return p == index || navigationUrl(p)
+	defer func() { h.Logger.Info(logging.NewMsgData(entry.RequestDetails.String(), entry)) }()
+
+	switch r.Method {
+	case http.MethodGet:
+		entry.ServeDetails = h.serveAsset(wr, r, &entry.PreloadDetails)
+		entry.ServeDetails.Method = methodServe
+	case http.MethodHead:
+		entry.ServeDetails = h.serveAsset(wr, r, nil)
+		entry.ServeDetails.Method = methodServeHead
+	case http.MethodOptions:
+		entry.ServeDetails = serveOptions(wr)
+	default:
+		entry.ServeDetails = serveMethodNotAllowed(wr)
+	}
 }
 
 func navigationUrl(p string) bool {
 	return filepath.Ext(p) == ""
 }
 
-func (h *handler) tryPush(wr http.ResponseWriter, r *http.Request) (result pushDetails) {
-	result.ServerChecksum = h.Checksum
-	if !requestTriggersPush(r.URL.Path, h.Index) {
-		result.RequestTriggersPush = &boolFalse
-		return
+func setCacheControl(header http.Header, cc string) {
+	if cc != "" {
+		header.Set("Cache-Control", cc)
 	}
-	result.RequestTriggersPush = &boolTrue
-
-	pusher, ok := wr.(http.Pusher)
-	if !ok {
-		result.ClientSupportsPush = &boolFalse
-		return
-	}
-	result.ClientSupportsPush = &boolTrue
-
-	if clientHasAssets(r, h.Checksum, &result) {
-		result.ClientNeedsAssets = &boolFalse
-		return
-	}
-	result.ClientNeedsAssets = &boolTrue
-
-	http.SetCookie(wr, &http.Cookie{
-		Name:     pushCookieName,
-		Value:    h.Checksum,
-		Path:     "/",
-		Domain:   r.Host,
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   int(time.Hour * 24 * 365 / time.Second), // 1 year in seconds
-	})
-
-	result.PushAttempted = true
-	result.Assets = make([]string, 0, len(h.Assets))
-
-	opts := http.PushOptions{Method: http.MethodGet}
-	for _, asset := range h.Assets {
-		if asset.Lazy {
-			continue
-		}
-
-		if requestTriggersPush(asset.Url, h.Index) {
-			continue
-		}
-
-		result.Assets = append(result.Assets, asset.Url)
-		_ = pusher.Push(asset.Url, &opts)
-	}
-
-	return
 }
 
-func clientHasAssets(r *http.Request, checksum string, p *pushDetails) bool {
-	c, err := r.Cookie(pushCookieName)
-	switch {
-	case errors.Is(err, http.ErrNoCookie):
-		return false
-	case c.Value == checksum:
-		p.ClientChecksum = c.Value
-		return true
-	default:
-		p.ClientChecksum = c.Value
-		return false
+func setValidators(header http.Header, v validators) {
+	if v.Etag != "" {
+		header.Set("ETag", v.Etag)
+	}
+	if !v.LastModified.IsZero() {
+		header.Set("Last-Modified", v.LastModified.Format(http.TimeFormat))
 	}
 }
 
-func (h *handler) serveAsset(wr http.ResponseWriter, r *http.Request) (result serveDetails) {
-	asset, ok := h.LookupPath[r.URL.Path]
+// serveAsset serves the asset requested by r. If preload is not nil, preload hints
+// are added to successful responses for the site's index.
+func (h *handler) serveAsset(wr http.ResponseWriter, r *http.Request, preload *preloadDetails) (result serveDetails) {
+	asset, ok := h.lookup(r, &result)
 	if !ok {
 		result.Status = http.StatusNotFound
 		http.NotFound(wr, r)
 		return
 	}
 
-	if asset.Etag != "" {
-		if etag := r.Header.Get("If-None-Match"); etag == asset.Etag {
-			result.Status = http.StatusNotModified
-			wr.WriteHeader(http.StatusNotModified)
-			return
-		}
-	}
-
 	header := wr.Header()
-	header.Set("ETag", asset.Etag)
-	header.Set("Content-Type", string(asset.ContentType))
+	header.Add("Vary", "Accept-Encoding")
+	header.Set("Accept-Ranges", "bytes")
 
-	encodings := r.Header.Get("Accept-Encoding")
-	for _, datum := range asset.Data {
+	// The cache policy only applies to successful responses, so that errors are not cached.
+	cc := h.CacheControl[asset]
 
-		es := datum.ContentEncoding.String()
-		if strings.Contains(encodings, es) || manifest.Identity == datum.ContentEncoding {
-			header.Set("Content-Encoding", es)
-		}
+	datum, ok := negotiateEncoding(r, asset.Data, h.Encodings)
+	if !ok {
+		result.Status = http.StatusNotAcceptable
+		http.Error(wr, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+		return
+	}
 
-		result.Status = http.StatusOK
-		wr.WriteHeader(http.StatusOK)
+	v := representationValidators(asset, datum)
+	switch status := evaluatePreconditions(r, v); status {
+	case http.StatusNotModified:
+		result.Status = status
+		setValidators(header, v)
+		setCacheControl(header, cc)
+		wr.WriteHeader(status)
+		return
+	case http.StatusPreconditionFailed:
+		result.Status = status
+		setValidators(header, v)
+		http.Error(wr, http.StatusText(status), status)
+		return
+	}
 
-		_, err := wr.Write(datum.Data)
-		if err != nil {
-			panic(err)
+	if rangeRequested(r) && parseAcceptEncoding(r).acceptable(manifest.Identity) {
+		identity, ok := identityEncoded(asset.Data)
+		if ok && ifRangeMatches(r, representationValidators(asset, identity)) && serveRanges(wr, r, asset, identity, cc, &result) {
+			return
 		}
+	}
 
-		result.Size = len(datum.Data)
+	// Preload hints are only sent with the final response for the index, so that the
+	// hints and the cookie that records them are never sent with errors.
+	if preload != nil {
+		*preload = h.tryPreload(wr, r, asset)
+	}
 
-		return
+	setValidators(header, v)
+	header.Set("Content-Type", string(asset.ContentType))
+	setCacheControl(header, cc)
+
+	result.ContentEncoding = datum.ContentEncoding.String()
+	if datum.ContentEncoding != manifest.Identity {
+		header.Set("Content-Encoding", result.ContentEncoding)
 	}
 
-	panic("no acceptable encoding found")
+	result.Status = http.StatusOK
+	result.Size = writeData(wr, r, http.StatusOK, datum.Data)
+	return
 }
@@ -0,0 +1,234 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package watch watches a webroot for changes.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/ajjensen13/dayspa/internal/logging"
+)
+
+// Config configures a watcher.
+type Config struct {
+	// Debounce is how long the webroot must be quiet before a change is reported.
+	// This keeps partially copied builds from being loaded.
+	Debounce time.Duration
+	// PollInterval is how often the webroot is scanned when polling.
+	PollInterval time.Duration
+	// Poll forces polling, even when inotify is available.
+	Poll bool
+}
+
+// Watch calls onChange whenever files under root change, until ctx is done.
+// Changes are detected with inotify. If inotify is unavailable, Watch falls back to
+// polling. onChange is never called concurrently.
+//
+// If root is a file (e.g. an archive), only that file is watched. With inotify, its
+// directory is watched (not recursively), so that the file is also noticed when it
+// is replaced rather than written in place.
+func Watch(ctx context.Context, root string, cfg Config, lg logging.Logger, onChange func()) error {
+	events := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+
+	if !cfg.Poll {
+		w, err := watchNotify(root, notify, lg)
+		if err == nil {
+			defer w.Close()
+			lg.Infof("watching %s for changes with inotify", root)
+			return debounce(ctx, events, cfg.Debounce, onChange)
+		}
+		lg.Warningf("failed to watch %s with inotify, falling back to polling: %v", root, err)
+	}
+
+	lg.Infof("watching %s for changes by polling every %v", root, cfg.PollInterval)
+	go poll(ctx, root, cfg.PollInterval, notify, lg)
+	return debounce(ctx, events, cfg.Debounce, onChange)
+}
+
+// debounce calls onChange once events have stopped arriving for the given duration.
+func debounce(ctx context.Context, events <-chan struct{}, d time.Duration, onChange func()) error {
+	timer := time.NewTimer(d)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-events:
+			timer.Stop()
+			select {
+			case <-timer.C:
+			default:
+			}
+			timer.Reset(d)
+		case <-timer.C:
+			onChange()
+		}
+	}
+}
+
+func watchNotify(root string, notify func(), lg logging.Logger) (*fsnotify.Watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// file is the watched file when root is not a directory. Events for other files are ignored.
+	var file string
+	fi, err := os.Stat(root)
+	switch {
+	case err != nil:
+	case fi.IsDir():
+		err = addRecursive(w, root)
+	default:
+		file = filepath.Clean(root)
+		err = w.Add(filepath.Dir(file))
+	}
+	if err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+
+	go func() {
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+
+				if file != "" && filepath.Clean(ev.Name) != file {
+					continue
+				}
+
+				// inotify is not recursive, so new directories must be watched as they appear.
+				if file == "" && ev.Op&fsnotify.Create != 0 {
+					if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+						if err := addRecursive(w, ev.Name); err != nil {
+							lg.Warningf("failed to watch new directory %s: %v", ev.Name, err)
+						}
+					}
+				}
+
+				notify()
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				lg.Warningf("error watching %s: %v", root, err)
+			}
+		}
+	}()
+
+	return w, nil
+}
+
+func addRecursive(w *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(fpath string, info os.FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case !info.IsDir():
+			return nil
+		}
+
+		err = w.Add(fpath)
+		if err != nil {
+			return fmt.Errorf("failed to watch directory %s: %w", fpath, err)
+		}
+		return nil
+	})
+}
+
+func poll(ctx context.Context, root string, interval time.Duration, notify func(), lg logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev, err := snapshot(root)
+	if err != nil {
+		lg.Warningf("failed to scan %s: %v", root, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next, err := snapshot(root)
+		if err != nil {
+			lg.Warningf("failed to scan %s: %v", root, err)
+			continue
+		}
+
+		if !next.equal(prev) {
+			notify()
+		}
+		prev = next
+	}
+}
+
+type fileState struct {
+	Size    int64
+	ModTime time.Time
+}
+
+type snapshotType map[string]fileState
+
+func snapshot(root string) (snapshotType, error) {
+	result := make(snapshotType)
+	err := filepath.Walk(root, func(fpath string, info os.FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case info.IsDir():
+			return nil
+		}
+
+		result[fpath] = fileState{Size: info.Size(), ModTime: info.ModTime()}
+		return nil
+	})
+	return result, err
+}
+
+func (s snapshotType) equal(o snapshotType) bool {
+	if len(s) != len(o) {
+		return false
+	}
+
+	for k, v := range s {
+		if ov, ok := o[k]; !ok || ov.Size != v.Size || !ov.ModTime.Equal(v.ModTime) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package dayspa serves single page apps from within an existing Go program.
+//
+// A site is loaded once, from any fs.FS, and then served by a Handler:
+//
+//	site, err := dayspa.LoadNgsw(dayspa.Dir("/var/www/html"), dayspa.VerifyFail, dayspa.LoadOptions{}, nil)
+//	if err != nil {
+//		return err
+//	}
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("/api/", apiHandler)
+//	mux.Handle("/", dayspa.Handler(site, dayspa.WithFallbackExclude("/api/**")))
+//
+// To ship a single binary, the site can be compiled in with go:embed. Use fs.Sub so
+// that the root of the file system is the webroot:
+//
+//	//go:embed dist/app
+//	var dist embed.FS
+//
+//	webroot, err := fs.Sub(dist, "dist/app")
+//	if err != nil {
+//		return err
+//	}
+//	site, err := dayspa.LoadNgsw(webroot, dayspa.VerifyFail, dayspa.LoadOptions{}, nil)
+package dayspa
+
+import (
+	"io/fs"
+
+	"github.com/ajjensen13/dayspa/internal/archive"
+	"github.com/ajjensen13/dayspa/internal/cache"
+	"github.com/ajjensen13/dayspa/internal/load"
+	"github.com/ajjensen13/dayspa/internal/load/ngsw"
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/manifest"
+	"github.com/ajjensen13/dayspa/internal/serve"
+)
+
+type (
+	// Site is a loaded site.
+	Site = manifest.Site
+	// EncodedAsset is a single asset of a site, in every supported encoding.
+	EncodedAsset = manifest.EncodedAsset
+	// ContentEncoding is an HTTP content encoding.
+	ContentEncoding = manifest.ContentEncoding
+
+	// Logger logs payloads at various severities.
+	Logger = logging.Logger
+	// MsgData is a log payload consisting of a message and structured data.
+	MsgData = logging.MsgData
+
+	// LoadOptions configures how the assets of a site are encoded.
+	LoadOptions = load.Options
+	// Verify determines what happens when files do not match the hashTable of ngsw.json.
+	Verify = ngsw.Verify
+
+	// CachePolicy assigns Cache-Control values to assets.
+	CachePolicy = cache.Policy
+	// CacheRule overrides the built-in CachePolicy for some assets. It is built with
+	// ParseCacheRule, or from a Selector and Value in the same form.
+	CacheRule = cache.Rule
+
+	// Fallback configures how navigation requests for unknown paths are answered.
+	Fallback = serve.FallbackConfig
+
+	// SiteHandler is an http.Handler that serves a site. The site can be replaced while
+	// serving with SiteHandler.Swap.
+	SiteHandler = serve.SiteHandler
+)
+
+// Content encodings.
+const (
+	Identity = manifest.Identity
+	Gzip     = manifest.Gzip
+	Deflate  = manifest.Deflate
+	Brotli   = manifest.Brotli
+	Zstd     = manifest.Zstd
+)
+
+// Hash verification for LoadNgsw.
+const (
+	VerifyFail = ngsw.VerifyFail
+	VerifyWarn = ngsw.VerifyWarn
+	VerifyOff  = ngsw.VerifyOff
+)
+
+// ParseCacheRule parses a rule of the form "<selector>=<cache-control>".
+// See the dayspa command's --cache-rule flag for the supported selectors.
+func ParseCacheRule(s string) (CacheRule, error) {
+	return cache.ParseRule(s)
+}
+
+// Load loads a webroot into a Site, detecting how it was built from its contents
+// (e.g. an ngsw.json). Webroots that are not recognized are loaded like LoadFilesystem.
+// Every loader uses its defaults (e.g. VerifyFail). If lg is nil, nothing is logged.
+func Load(webroot fs.FS, opts LoadOptions, lg Logger) (*Site, error) {
+	l, err := load.Lookup(load.Auto)
+	if err != nil {
+		return nil, err
+	}
+	return l.Load(webroot, nil, opts, loggerOrDiscard(lg))
+}
+
+// LoadNgsw loads an Angular webroot, described by its ngsw.json, into a Site. Files are
+// verified against the hashTable of ngsw.json, as determined by verify.
+// If lg is nil, nothing is logged.
+func LoadNgsw(webroot fs.FS, verify Verify, opts LoadOptions, lg Logger) (*Site, error) {
+	return load.Ngsw(webroot, verify, opts, loggerOrDiscard(lg))
+}
+
+// LoadVite loads a Vite webroot, described by its build manifest, into a Site.
+// If lg is nil, nothing is logged.
+func LoadVite(webroot fs.FS, opts LoadOptions, lg Logger) (*Site, error) {
+	return load.Vite(webroot, opts, loggerOrDiscard(lg))
+}
+
+// LoadCra loads a Create React App webroot, described by its asset-manifest.json, into a Site.
+// If lg is nil, nothing is logged.
+func LoadCra(webroot fs.FS, opts LoadOptions, lg Logger) (*Site, error) {
+	return load.Cra(webroot, opts, loggerOrDiscard(lg))
+}
+
+// LoadWebpack loads a webpack webroot, described by its stats.json or webpack-assets-manifest
+// output, into a Site. manifestPath is relative to the webroot; if it is empty, stats.json and
+// then assets-manifest.json are tried. If lg is nil, nothing is logged.
+func LoadWebpack(webroot fs.FS, manifestPath string, opts LoadOptions, lg Logger) (*Site, error) {
+	return load.Webpack(webroot, manifestPath, opts, loggerOrDiscard(lg))
+}
+
+// LoadFilesystem loads every file in a webroot into a Site.
+// If lg is nil, nothing is logged.
+func LoadFilesystem(webroot fs.FS, opts LoadOptions, lg Logger) (*Site, error) {
+	return load.Filesystem(webroot, opts, loggerOrDiscard(lg))
+}
+
+// Dir returns the file system of the directory dir, like os.DirFS, but described by
+// dir in load logs.
+func Dir(dir string) fs.FS {
+	return load.Dir(dir)
+}
+
+// OpenArchive reads a tar, tar.gz or zip archive into memory, so that a site can be
+// loaded from it without extracting it to disk. The returned file system is rooted at
+// the directory root within the archive (e.g. "dist/app", or "." for the whole archive).
+func OpenArchive(fpath, root string) (fs.FS, error) {
+	return archive.Open(fpath, root)
+}
+
+func loggerOrDiscard(lg Logger) Logger {
+	if lg == nil {
+		return logging.Discard
+	}
+	return lg
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright © 2020  A. Jensen <jensen.aaro@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package dayspa
+
+import (
+	"github.com/ajjensen13/dayspa/internal/logging"
+	"github.com/ajjensen13/dayspa/internal/serve"
+)
+
+// Option configures a SiteHandler.
+type Option func(o *options)
+
+type options struct {
+	logger Logger
+	config serve.Config
+}
+
+// WithLogger logs each request to lg. By default, requests are not logged.
+func WithLogger(lg Logger) Option {
+	return func(o *options) {
+		o.logger = lg
+	}
+}
+
+// WithCachePolicy assigns Cache-Control headers with p. By default, indexes and build
+// manifests are revalidated, hashed files are immutable, and other assets are cached
+// for an hour.
+func WithCachePolicy(p CachePolicy) Option {
+	return func(o *options) {
+		o.config.Cache = p
+	}
+}
+
+// WithFallback configures how navigation requests for unknown paths are answered.
+// By default, they are answered with the site's index.
+func WithFallback(f Fallback) Option {
+	return func(o *options) {
+		o.config.Fallback = f
+	}
+}
+
+// WithFallbackExclude excludes paths matching the glob patterns (e.g. "/api/**") from
+// being answered with the site's index.
+func WithFallbackExclude(globs ...string) Option {
+	return func(o *options) {
+		o.config.Fallback.Exclude = append(o.config.Fallback.Exclude, globs...)
+	}
+}
+
+// WithEncodings restricts the content encodings that are served. Identity is always
+// served. By default, every encoding is served.
+func WithEncodings(encodings ...ContentEncoding) Option {
+	return func(o *options) {
+		o.config.Encodings = encodings
+	}
+}
+
+// NewHandler returns a SiteHandler that serves site.
+func NewHandler(site *Site, opts ...Option) (*SiteHandler, error) {
+	o := options{logger: logging.Discard, config: serve.DefaultConfig()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return serve.Handler(site, o.logger, o.config)
+}
+
+// Handler is like NewHandler, but panics if the options are invalid (e.g. a malformed glob pattern).
+func Handler(site *Site, opts ...Option) *SiteHandler {
+	result, err := NewHandler(site, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}